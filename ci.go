@@ -0,0 +1,53 @@
+package colorprofile
+
+import "strconv"
+
+// ciProvider describes the color support of a CI provider's log viewer.
+type ciProvider struct {
+	// name is the name of the CI provider.
+	name string
+	// detect reports whether the process runs on the CI provider.
+	detect func(env environ) bool
+	// profile is the color profile the log viewer renders.
+	profile Profile
+}
+
+// ciProviders are the CI providers rendering ANSI colors in their log
+// viewers.
+var ciProviders = []ciProvider{
+	{name: "GitHub Actions", detect: envTrue("GITHUB_ACTIONS"), profile: TrueColor},
+	{name: "GitLab CI", detect: envTrue("GITLAB_CI"), profile: ANSI256},
+	{name: "Buildkite", detect: envTrue("BUILDKITE"), profile: ANSI256},
+	{name: "CircleCI", detect: envTrue("CIRCLECI"), profile: ANSI256},
+	{name: "Drone", detect: envTrue("DRONE"), profile: ANSI},
+	{name: "Azure Pipelines", detect: envTrue("TF_BUILD"), profile: ANSI},
+	{name: "Jenkins", detect: jenkinsAnsiColor, profile: ANSI},
+}
+
+// envTrue returns a function reporting whether the environment variable key
+// is set to a true value.
+func envTrue(key string) func(env environ) bool {
+	return func(env environ) bool {
+		v, _ := strconv.ParseBool(env.get(key))
+		return v
+	}
+}
+
+// jenkinsAnsiColor reports whether the process runs on Jenkins with the
+// AnsiColor plugin enabled. Jenkins shows escape sequences verbatim without
+// the plugin, which sets TERM for the build steps it wraps.
+func jenkinsAnsiColor(env environ) bool {
+	term := env.get("TERM")
+	return len(env.get("JENKINS_URL")) > 0 && len(term) > 0 && term != dumbTerm
+}
+
+// ciColorProfile returns the color profile of the CI provider the process
+// runs on, if any.
+func ciColorProfile(env environ) (ciProvider, bool) {
+	for _, ci := range ciProviders {
+		if ci.detect(env) {
+			return ci, true
+		}
+	}
+	return ciProvider{}, false
+}
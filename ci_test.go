@@ -0,0 +1,104 @@
+package colorprofile
+
+import (
+	"io"
+	"testing"
+)
+
+func TestCIColorProfile(t *testing.T) {
+	cases := []struct {
+		name     string
+		environ  []string
+		ignoreCI bool
+		expected Profile
+	}{
+		{
+			name:     "not CI",
+			environ:  []string{},
+			expected: NoTTY,
+		},
+		{
+			name:     "generic CI",
+			environ:  []string{"CI=true"},
+			expected: NoTTY,
+		},
+		{
+			name:     "GitHub Actions",
+			environ:  []string{"CI=true", "GITHUB_ACTIONS=true"},
+			expected: TrueColor,
+		},
+		{
+			name:     "GitHub Actions, ignored",
+			environ:  []string{"CI=true", "GITHUB_ACTIONS=true"},
+			ignoreCI: true,
+			expected: NoTTY,
+		},
+		{
+			name:     "GitHub Actions, NO_COLOR=1",
+			environ:  []string{"CI=true", "GITHUB_ACTIONS=true", "NO_COLOR=1"},
+			expected: ASCII,
+		},
+		{
+			name:     "GitLab CI",
+			environ:  []string{"CI=true", "GITLAB_CI=true"},
+			expected: ANSI256,
+		},
+		{
+			name:     "Buildkite",
+			environ:  []string{"CI=true", "BUILDKITE=true"},
+			expected: ANSI256,
+		},
+		{
+			name:     "CircleCI",
+			environ:  []string{"CI=true", "CIRCLECI=true"},
+			expected: ANSI256,
+		},
+		{
+			name:     "Drone",
+			environ:  []string{"CI=true", "DRONE=true"},
+			expected: ANSI,
+		},
+		{
+			name:     "Azure Pipelines",
+			environ:  []string{"TF_BUILD=True"},
+			expected: ANSI,
+		},
+		{
+			name:     "Jenkins",
+			environ:  []string{"JENKINS_URL=https://ci.example.com/"},
+			expected: NoTTY,
+		},
+		{
+			name:     "Jenkins with AnsiColor",
+			environ:  []string{"JENKINS_URL=https://ci.example.com/", "TERM=xterm"},
+			expected: ANSI,
+		},
+		{
+			name:     "Jenkins with AnsiColor, xterm-256color",
+			environ:  []string{"JENKINS_URL=https://ci.example.com/", "TERM=xterm-256color"},
+			expected: ANSI,
+		},
+		{
+			name:     "GitLab CI, CLICOLOR_FORCE=1, COLORTERM=truecolor",
+			environ:  []string{"GITLAB_CI=true", "TERM=xterm", "CLICOLOR_FORCE=1", "COLORTERM=truecolor"},
+			expected: TrueColor,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Detector{IgnoreCI: tc.ignoreCI}
+			// CI jobs write their standard output to a pipe.
+			if p := d.newDetection(tc.environ).colorProfile(false, true); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestDetectCIOutput(t *testing.T) {
+	// Only the standard output and error end up in the CI log.
+	if p := Detect(io.Discard, []string{"CI=true", "GITHUB_ACTIONS=true"}); p != NoTTY {
+		t.Errorf("expected NoTTY, got %v", p)
+	}
+}
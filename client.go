@@ -0,0 +1,80 @@
+package colorprofile
+
+import (
+	"io"
+	"strconv"
+)
+
+// Client describes the terminal of a remote client, such as an SSH client
+// connecting to a server. Its color profile is detected from what the client
+// sends rather than from the local process, see [Detector.DetectClient].
+type Client struct {
+	// Term is the TERM the client requested with its pseudo-terminal, e.g.
+	// in an SSH pty-req. If empty, the client didn't request a
+	// pseudo-terminal, so its output isn't a terminal.
+	Term string
+
+	// Width and Height are the window size the client requested with its
+	// pseudo-terminal. They're only reported in the explanation.
+	Width, Height int
+
+	// Environ are the environment variables the client forwarded, in the
+	// [os.Environ] format, e.g. COLORTERM or LC_TERMINAL.
+	Environ []string
+
+	// Session is the channel to query the client terminal with when
+	// [Detector.SSH] is [SSHQuery], e.g. an SSH session. The client terminal
+	// replies are read from it, so it must not be read concurrently. If
+	// nil, the client terminal isn't queried.
+	//
+	// Clients that don't reply within 2 seconds are detected from TERM. If
+	// the session has no SetDeadline method, the pending read is abandoned
+	// and consumes the next input of the session when it returns.
+	Session io.ReadWriter
+}
+
+// DetectClient returns the color profile of a remote client terminal. It
+// follows the [Detect] rules, using the client forwarded environment and the
+// pseudo-terminal TERM, but never looks at the local process: the local
+// terminal, multiplexers, and CI providers are ignored. The client is
+// assumed to connect over SSH, so [Detector.SSH] applies.
+//
+// The terminfo entry of TERM is looked up in the system and built-in
+// databases, unless [Detector.LoadTerminfo] is set. The TERMINFO,
+// TERMINFO_DIRS, and HOME variables the client forwarded are ignored, and so
+// are TERM names that aren't plain file names, e.g. "../x".
+func (d *Detector) DetectClient(c Client) Profile {
+	return d.ExplainClient(c).Profile
+}
+
+// ExplainClient is like [Detector.DetectClient] but also returns the reasons
+// that led to the detected color profile.
+func (d *Detector) ExplainClient(c Client) Explanation {
+	env := c.Environ
+	if len(c.Term) > 0 {
+		// The pseudo-terminal TERM takes precedence over a forwarded one.
+		env = append(env[:len(env):len(env)], "TERM="+c.Term)
+	}
+
+	s := d.newDetection(env)
+	s.remote = true
+	s.session = c.Session
+	if !s.ssh {
+		s.ssh = true
+		s.explainf("detecting a remote client, using the %s policy", d.SSH)
+	}
+
+	isatty := len(c.Term) > 0
+	if isatty {
+		size := "unknown size"
+		if c.Width > 0 && c.Height > 0 {
+			size = strconv.Itoa(c.Width) + "x" + strconv.Itoa(c.Height)
+		}
+		s.explainf("client requested a %s pseudo-terminal with TERM=%s", size, c.Term)
+	} else {
+		s.explainf("client didn't request a pseudo-terminal")
+	}
+
+	p := s.detectTerminal(isatty, false, nil)
+	return Explanation{Profile: p, Reasons: s.reasons, Limits: s.limits}
+}
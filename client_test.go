@@ -0,0 +1,245 @@
+package colorprofile
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestDetectClient(t *testing.T) {
+	noProbe := func(name string, _ ...string) ([]byte, error) {
+		t.Errorf("unexpected %s run", name)
+		return nil, errNotFound
+	}
+
+	cases := []struct {
+		name     string
+		client   Client
+		ssh      SSHPolicy
+		goos     string
+		expected Profile
+	}{
+		{
+			name:     "no pty",
+			client:   Client{Environ: []string{"TERM=xterm-256color", "COLORTERM=truecolor"}},
+			expected: NoTTY,
+		},
+		{
+			name:     "no pty, forced",
+			client:   Client{Environ: []string{"CLICOLOR_FORCE=1"}},
+			goos:     "windows",
+			expected: ANSI,
+		},
+		{
+			name:     "pty",
+			client:   Client{Term: "xterm-256color", Width: 80, Height: 24},
+			expected: ANSI256,
+		},
+		{
+			name:     "dumb pty",
+			client:   Client{Term: "dumb"},
+			expected: NoTTY,
+		},
+		{
+			name:     "forwarded COLORTERM",
+			client:   Client{Term: "xterm-256color", Environ: []string{"COLORTERM=truecolor"}},
+			expected: TrueColor,
+		},
+		{
+			name:     "pty TERM takes precedence",
+			client:   Client{Term: "xterm", Environ: []string{"TERM=xterm-direct"}},
+			expected: ANSI,
+		},
+		{
+			name:     "forwarded NO_COLOR",
+			client:   Client{Term: "xterm-256color", Environ: []string{"NO_COLOR=1"}},
+			expected: ASCII,
+		},
+		{
+			name:     "forwarded TMUX isn't probed",
+			client:   Client{Term: "tmux-256color", Environ: []string{"TMUX=/tmp/tmux-1000/default,1,0", "COLORTERM=truecolor"}},
+			expected: ANSI256,
+		},
+		{
+			name: "LC_TERMINAL",
+			client: Client{
+				Term:    "xterm-256color",
+				Environ: []string{"LC_TERMINAL=iTerm2", "LC_TERMINAL_VERSION=3.5.4"},
+			},
+			ssh:      SSHLCTerminal,
+			expected: TrueColor,
+		},
+		{
+			name:     "query",
+			client:   Client{Term: "xterm-256color", Session: newFakeTTY("\x1bP1+r524742\x1b\\" + da1Reply)},
+			ssh:      SSHQuery,
+			expected: TrueColor,
+		},
+		{
+			name:     "query, no RGB",
+			client:   Client{Term: "xterm-256color", Session: newFakeTTY("\x1bP0+r524742\x1b\\" + da1Reply)},
+			ssh:      SSHQuery,
+			expected: ANSI256,
+		},
+		{
+			name:     "query, no session",
+			client:   Client{Term: "xterm-256color"},
+			ssh:      SSHQuery,
+			expected: ANSI256,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Detector{
+				SSH:          tc.ssh,
+				GOOS:         tc.goos,
+				LoadTerminfo: noTerminfo,
+				RunCommand:   noProbe,
+				IsTerminal: func(uintptr) bool {
+					t.Error("unexpected terminal check")
+					return false
+				},
+			}
+			if p := d.DetectClient(tc.client); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestExplainClient(t *testing.T) {
+	tty := newFakeTTY("\x1bP1+r524742\x1b\\" + da1Reply)
+	d := &Detector{SSH: SSHQuery, LoadTerminfo: noTerminfo}
+	e := d.ExplainClient(Client{Term: "xterm-256color", Width: 120, Height: 40, Session: tty})
+	if e.Profile != TrueColor {
+		t.Errorf("expected TrueColor, got %v", e.Profile)
+	}
+	for _, want := range []string{
+		"detecting a remote client, using the query policy",
+		"client requested a 120x40 pseudo-terminal with TERM=xterm-256color",
+		"terminal reports true color support",
+	} {
+		if !strings.Contains(e.String(), want) {
+			t.Errorf("expected explanation to mention %q, got:\n%s", want, e)
+		}
+	}
+	if q := tty.queries.String(); !strings.Contains(q, ansi.XTGETTCAP("RGB", "Tc")) {
+		t.Errorf("expected the session to be queried, got %q", q)
+	}
+}
+
+// silentSession is a session whose client never replies.
+type silentSession struct {
+	closed chan struct{}
+}
+
+func (s silentSession) Write(p []byte) (int, error) { return len(p), nil }
+
+func (s silentSession) Read([]byte) (int, error) {
+	<-s.closed
+	return 0, io.EOF
+}
+
+func TestDetectClientSilentSession(t *testing.T) {
+	timeout := queryTimeout
+	queryTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { queryTimeout = timeout })
+
+	silent := silentSession{closed: make(chan struct{})}
+	t.Cleanup(func() { close(silent.closed) })
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	for name, session := range map[string]io.ReadWriter{
+		"without deadlines": silent,
+		"with deadlines":    server,
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := &Detector{SSH: SSHQuery, LoadTerminfo: noTerminfo}
+			done := make(chan Explanation, 1)
+			go func() {
+				done <- d.ExplainClient(Client{Term: "xterm-256color", Session: session})
+			}()
+
+			select {
+			case e := <-done:
+				if e.Profile != ANSI256 {
+					t.Errorf("expected ANSI256, got %v", e.Profile)
+				}
+				if !strings.Contains(e.String(), "can't query the terminal") {
+					t.Errorf("expected the query to fail, got:\n%s", e)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the detection is still waiting for the client")
+			}
+		})
+	}
+}
+
+func TestDetectClientHostileTerm(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "terminfo", "m", "myterm"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "rv"), 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "rv", "evil"), b, 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	abs, err := filepath.Abs(filepath.Join("testdata", "terminfo"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		client Client
+	}{
+		{
+			name:   "path traversal",
+			client: Client{Term: "x/" + strings.Repeat("../", 16) + filepath.ToSlash(dir) + "/rv/evil"},
+		},
+		{
+			name:   "parent directory",
+			client: Client{Term: ".." + filepath.ToSlash(dir) + "/rv/evil"},
+		},
+		{
+			name:   "TERMINFO",
+			client: Client{Term: "myterm", Environ: []string{"TERMINFO=" + abs}},
+		},
+		{
+			name:   "TERMINFO_DIRS",
+			client: Client{Term: "myterm", Environ: []string{"TERMINFO_DIRS=" + abs}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := new(Detector).ExplainClient(tc.client)
+			if e.Profile != ANSI {
+				t.Errorf("expected ANSI, got %v", e.Profile)
+			}
+			if strings.Contains(e.String(), "terminfo entry for") {
+				t.Errorf("expected no terminfo entry to be loaded, got:\n%s", e)
+			}
+		})
+	}
+
+	// The local environment still points to terminfo directories.
+	d := Detector{IsTerminal: fakeTerminal}
+	if p := d.Detect(&fakeFile{fd: 42}, []string{"TERM=myterm", "TERMINFO=" + abs}); p != TrueColor {
+		t.Errorf("expected TrueColor, got %v", p)
+	}
+}
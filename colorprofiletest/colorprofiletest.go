@@ -0,0 +1,274 @@
+// Package colorprofiletest provides simulated terminal environments to test
+// color profile detection and rendering deterministically.
+//
+// Each [Environment] describes a terminal setup: its environment variables,
+// operating system, whether the output is a terminal, the output of the
+// programs detection runs, and a fake [Terminal] answering queries. Tests can
+// assert how an application renders across the whole matrix with one table:
+//
+//	for _, env := range colorprofiletest.Environments() {
+//		t.Run(env.Name, func(t *testing.T) {
+//			var buf bytes.Buffer
+//			render(env.Writer(&buf))
+//			// Check buf against the expected output for env.Profile.
+//		})
+//	}
+package colorprofiletest
+
+import (
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/colorprofile"
+	"github.com/xo/terminfo"
+)
+
+// Environment is a simulated terminal environment.
+type Environment struct {
+	// Name describes the environment, e.g. "tmux in alacritty".
+	Name string
+
+	// Environ are the environment variables in the [os.Environ] format.
+	Environ []string
+
+	// GOOS is the operating system, using the runtime.GOOS values. If empty,
+	// the running operating system is used.
+	GOOS string
+
+	// WindowsMajor and WindowsBuild are the Windows NT major version and
+	// build number when GOOS is "windows".
+	WindowsMajor, WindowsBuild uint32
+
+	// IsTerminal tells whether the output is a terminal.
+	IsTerminal bool
+
+	// Commands are the standard outputs of the programs detection runs,
+	// keyed by program name, e.g. "tmux" for `tmux info`. Other programs
+	// aren't found.
+	Commands map[string]string
+
+	// Terminal is the fake terminal answering queries. If nil, queries fail.
+	Terminal *Terminal
+
+	// Profile is the color profile [colorprofile.Detect] detects in the
+	// environment.
+	Profile colorprofile.Profile
+}
+
+// With returns a copy of the environment with the given environment
+// variables, in the [os.Environ] format, set. A variable without "=" is
+// unset.
+func (e Environment) With(vars ...string) Environment {
+	env := make(map[string]string, len(e.Environ)+len(vars))
+	for _, kv := range e.Environ {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	for _, kv := range vars {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			delete(env, k)
+			continue
+		}
+		env[k] = v
+	}
+
+	e.Environ = make([]string, 0, len(env))
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		e.Environ = append(e.Environ, k+"="+env[k])
+	}
+	return e
+}
+
+// Getenv returns the value of an environment variable.
+func (e Environment) Getenv(key string) string {
+	for _, kv := range e.Environ {
+		if k, v, _ := strings.Cut(kv, "="); k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// Detector returns a detector running in the environment instead of the
+// real world. Terminfo entries are never found, and the output is never a
+// Windows console or pipe.
+func (e Environment) Detector() *colorprofile.Detector {
+	d := &colorprofile.Detector{
+		IsTerminal: func(uintptr) bool { return e.IsTerminal },
+		LoadTerminfo: func(string) (*terminfo.Terminfo, error) {
+			return nil, terminfo.ErrFileNotFound
+		},
+		RunCommand: func(name string, _ ...string) ([]byte, error) {
+			out, ok := e.Commands[name]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return []byte(out), nil
+		},
+		GOOS: e.GOOS,
+		WindowsVersion: func() (uint32, uint32) {
+			return e.WindowsMajor, e.WindowsBuild
+		},
+		OpenConsole: func(uintptr) (colorprofile.Console, error) {
+			return nil, os.ErrNotExist
+		},
+		PipeName: func(uintptr) (string, error) {
+			return "", os.ErrNotExist
+		},
+	}
+	if e.Terminal != nil {
+		d.TTY = e.Terminal
+	}
+	return d
+}
+
+// Output returns the output detection inspects. It's the standard output as
+// far as detection can tell, so CI providers are detected.
+func (e Environment) Output() io.Writer {
+	return output{}
+}
+
+// Detect detects the color profile in the environment with the default
+// detector settings. To change them, use [Environment.Detector]:
+//
+//	d := env.Detector()
+//	d.SSH = colorprofile.SSHQuery
+//	p := d.Detect(env.Output(), env.Environ)
+func (e Environment) Detect() colorprofile.Profile {
+	return e.Detector().Detect(e.Output(), e.Environ)
+}
+
+// Writer returns a writer downsampling the colors written to w to the color
+// profile detected in the environment, and degrading the styles the terminal
+// doesn't render.
+func (e Environment) Writer(w io.Writer) *colorprofile.Writer {
+	ex := e.Detector().Explain(e.Output(), e.Environ)
+	return &colorprofile.Writer{
+		Forward: &colorprofile.Output{Writer: w, Limits: ex.Limits},
+		Profile: ex.Profile,
+	}
+}
+
+// output is a fake standard output.
+type output struct{}
+
+func (output) Write(p []byte) (int, error) { return len(p), nil }
+func (output) Read([]byte) (int, error)    { return 0, io.EOF }
+func (output) Close() error                { return nil }
+func (output) Fd() uintptr                 { return os.Stdout.Fd() }
+
+// ITerm2OverSSH returns an environment running in iTerm2 3.5 over SSH. The
+// remote side only sees TERM=xterm-256color and the LC_TERMINAL variables
+// iTerm2 forwards, so it detects ANSI256 unless [colorprofile.SSHLCTerminal]
+// or [colorprofile.SSHQuery] is used.
+func ITerm2OverSSH() Environment {
+	return Environment{
+		Name: "iTerm2 over SSH",
+		Environ: []string{
+			"LC_TERMINAL=iTerm2",
+			"LC_TERMINAL_VERSION=3.5.4",
+			"SSH_CLIENT=192.0.2.1 50022 22",
+			"SSH_CONNECTION=192.0.2.1 50022 192.0.2.2 22",
+			"SSH_TTY=/dev/pts/0",
+			"TERM=xterm-256color",
+		},
+		GOOS:       "linux",
+		IsTerminal: true,
+		Terminal: &Terminal{
+			Caps: map[string]string{"RGB": "", "Tc": ""},
+		},
+		Profile: colorprofile.ANSI256,
+	}
+}
+
+// WindowsTerminal returns an environment running in Windows Terminal on
+// Windows 11.
+func WindowsTerminal() Environment {
+	return Environment{
+		Name: "Windows Terminal",
+		Environ: []string{
+			"WT_PROFILE_ID={61c54bbd-c2c6-5271-96e7-009a87ff44bf}",
+			"WT_SESSION=a3c5f8e2-4b1d-4f6a-9c2e-7d8b0e1f2a3b",
+		},
+		GOOS:         "windows",
+		WindowsMajor: 10,
+		WindowsBuild: 22631,
+		IsTerminal:   true,
+		Terminal: &Terminal{
+			Attributes: []int{61, 4, 6, 7, 14, 21, 22, 23, 24, 28, 32, 42},
+		},
+		Profile: colorprofile.TrueColor,
+	}
+}
+
+// TmuxInAlacritty returns an environment running in tmux 3.4, configured to
+// pass true colors through, in Alacritty.
+func TmuxInAlacritty() Environment {
+	return Environment{
+		Name: "tmux in alacritty",
+		Environ: []string{
+			"ALACRITTY_SOCKET=/run/user/1000/Alacritty-:0-1234.sock",
+			"COLORTERM=truecolor",
+			"TERM=tmux-256color",
+			"TERM_PROGRAM=tmux",
+			"TERM_PROGRAM_VERSION=3.4",
+			"TMUX=/tmp/tmux-1000/default,1234,0",
+			"TMUX_PANE=%0",
+		},
+		GOOS:       "linux",
+		IsTerminal: true,
+		Commands: map[string]string{
+			"tmux": " 196: Tc: (flag) true\n 197: RGB: [missing]\n",
+		},
+		Terminal: &Terminal{
+			Caps: map[string]string{"RGB": "", "Tc": ""},
+		},
+		Profile: colorprofile.TrueColor,
+	}
+}
+
+// GitHubActions returns an environment running in a GitHub Actions job,
+// whose log viewer renders true colors even though the output isn't a
+// terminal.
+func GitHubActions() Environment {
+	return Environment{
+		Name: "GitHub Actions",
+		Environ: []string{
+			"CI=true",
+			"GITHUB_ACTIONS=true",
+			"RUNNER_OS=Linux",
+		},
+		GOOS:    "linux",
+		Profile: colorprofile.TrueColor,
+	}
+}
+
+// LinuxConsole returns an environment running in the Linux virtual console,
+// which renders neither italics nor bright backgrounds.
+func LinuxConsole() Environment {
+	return Environment{
+		Name:       "Linux console",
+		Environ:    []string{"TERM=linux"},
+		GOOS:       "linux",
+		IsTerminal: true,
+		Terminal: &Terminal{
+			Attributes: []int{6},
+		},
+		Profile: colorprofile.ANSI,
+	}
+}
+
+// Environments returns all the canned environments.
+func Environments() []Environment {
+	return []Environment{
+		ITerm2OverSSH(),
+		WindowsTerminal(),
+		TmuxInAlacritty(),
+		GitHubActions(),
+		LinuxConsole(),
+	}
+}
@@ -0,0 +1,144 @@
+package colorprofiletest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/charmbracelet/colorprofile"
+)
+
+func TestEnvironments(t *testing.T) {
+	for _, env := range Environments() {
+		t.Run(env.Name, func(t *testing.T) {
+			if p := env.Detect(); p != env.Profile {
+				t.Errorf("expected %v, got %v", env.Profile, p)
+			}
+		})
+	}
+}
+
+func TestEnvironmentDetector(t *testing.T) {
+	cases := []struct {
+		name     string
+		env      Environment
+		ssh      colorprofile.SSHPolicy
+		expected colorprofile.Profile
+	}{
+		{"iTerm2 over SSH, LC_TERMINAL", ITerm2OverSSH(), colorprofile.SSHLCTerminal, colorprofile.TrueColor},
+		{"iTerm2 over SSH, query", ITerm2OverSSH(), colorprofile.SSHQuery, colorprofile.TrueColor},
+		{"iTerm2 over SSH, query without RGB", withTerminal(ITerm2OverSSH(), &Terminal{}), colorprofile.SSHQuery, colorprofile.ANSI256},
+		{"iTerm2 over SSH, NO_COLOR", ITerm2OverSSH().With("NO_COLOR=1"), colorprofile.SSHQuery, colorprofile.ASCII},
+		{"tmux in alacritty, no Tc", withCommands(TmuxInAlacritty(), nil), colorprofile.SSHTrustTerm, colorprofile.ANSI256},
+		{"GitHub Actions, no GITHUB_ACTIONS", GitHubActions().With("GITHUB_ACTIONS"), colorprofile.SSHTrustTerm, colorprofile.NoTTY},
+		{"Windows Terminal, Windows 7", withWindows(WindowsTerminal(), 6, 7601), colorprofile.SSHTrustTerm, colorprofile.TrueColor},
+		{"Windows 7 console", withWindows(WindowsTerminal().With("WT_SESSION", "WT_PROFILE_ID"), 6, 7601), colorprofile.SSHTrustTerm, colorprofile.NoTTY},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.env.Detector()
+			d.SSH = tc.ssh
+			if p := d.Detect(tc.env.Output(), tc.env.Environ); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func withTerminal(e Environment, t *Terminal) Environment {
+	e.Terminal = t
+	return e
+}
+
+func withCommands(e Environment, commands map[string]string) Environment {
+	e.Commands = commands
+	return e
+}
+
+func withWindows(e Environment, major, build uint32) Environment {
+	e.WindowsMajor, e.WindowsBuild = major, build
+	return e
+}
+
+func TestEnvironmentWith(t *testing.T) {
+	env := LinuxConsole().With("NO_COLOR=1", "TERM=linux-16color", "MISSING")
+	expected := []string{"NO_COLOR=1", "TERM=linux-16color"}
+	if len(env.Environ) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, env.Environ)
+	}
+	for i := range expected {
+		if env.Environ[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected, env.Environ)
+		}
+	}
+	if v := env.Getenv("TERM"); v != "linux-16color" {
+		t.Errorf("expected TERM=linux-16color, got %q", v)
+	}
+	if v := LinuxConsole().Getenv("TERM"); v != "linux" {
+		t.Errorf("expected the original environment to be unchanged, got TERM=%q", v)
+	}
+}
+
+var writer_cases = []struct {
+	name              string
+	input             string
+	expectedTrueColor string
+	expectedANSI256   string
+	expectedANSI      string
+	expectedLinux     string
+}{
+	{
+		name:              "true color fg",
+		input:             "hello \x1b[38;2;255;133;55mworld\x1b[m", // #ff8537
+		expectedTrueColor: "hello \x1b[38;2;255;133;55mworld\x1b[m",
+		expectedANSI256:   "hello \x1b[38;5;209mworld\x1b[m",
+		expectedANSI:      "hello \x1b[91mworld\x1b[m",
+		expectedLinux:     "hello \x1b[91mworld\x1b[m",
+	},
+	{
+		name:              "256 color bg",
+		input:             "\x1b[48;5;196mhello world\x1b[m",
+		expectedTrueColor: "\x1b[48;5;196mhello world\x1b[m",
+		expectedANSI256:   "\x1b[48;5;196mhello world\x1b[m",
+		expectedANSI:      "\x1b[101mhello world\x1b[m",
+		expectedLinux:     "\x1b[41mhello world\x1b[m",
+	},
+	{
+		name:              "italic",
+		input:             "\x1b[1;3mhello\x1b[23m world\x1b[m",
+		expectedTrueColor: "\x1b[1;3mhello\x1b[23m world\x1b[m",
+		expectedANSI256:   "\x1b[1;3mhello\x1b[23m world\x1b[m",
+		expectedANSI:      "\x1b[1;3mhello\x1b[23m world\x1b[m",
+		expectedLinux:     "\x1b[1mhello\x1b[23m world\x1b[m",
+	},
+}
+
+func TestEnvironmentWriter(t *testing.T) {
+	for _, c := range writer_cases {
+		for _, env := range Environments() {
+			t.Run(c.name+"/"+env.Name, func(t *testing.T) {
+				var buf bytes.Buffer
+				if _, err := io.WriteString(env.Writer(&buf), c.input); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				var expected string
+				switch {
+				case env.Name == LinuxConsole().Name:
+					// The Linux console has limits.
+					expected = c.expectedLinux
+				case env.Profile == colorprofile.TrueColor:
+					expected = c.expectedTrueColor
+				case env.Profile == colorprofile.ANSI256:
+					expected = c.expectedANSI256
+				case env.Profile == colorprofile.ANSI:
+					expected = c.expectedANSI
+				}
+				if buf.String() != expected {
+					t.Errorf("expected %q, got %q", expected, buf.String())
+				}
+			})
+		}
+	}
+}
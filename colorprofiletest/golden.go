@@ -0,0 +1,281 @@
+package colorprofiletest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/colorprofile"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// UpdateEnv is the environment variable that, when set to true, makes
+// [AssertGolden] write the golden files instead of comparing with them.
+const UpdateEnv = "COLORPROFILE_UPDATE"
+
+// updating reports whether golden files are written instead of compared,
+// because of an -update flag defined by the test binary, or [UpdateEnv].
+// The flag isn't defined here, so it doesn't conflict with the -update flag
+// of other golden file packages.
+func updating() bool {
+	if v, err := strconv.ParseBool(os.Getenv(UpdateEnv)); err == nil {
+		return v
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			v, _ := g.Get().(bool)
+			return v
+		}
+		v, _ := strconv.ParseBool(f.Value.String())
+		return v
+	}
+	return false
+}
+
+// goldenProfiles are the color profiles golden files render, best first.
+var goldenProfiles = []colorprofile.Profile{
+	colorprofile.TrueColor,
+	colorprofile.ANSI256,
+	colorprofile.ANSI,
+	colorprofile.ASCII,
+	colorprofile.NoTTY,
+}
+
+// AssertGolden renders input through a [colorprofile.Writer] at every color
+// profile, and compares the outputs with the golden file
+// testdata/<test name>.golden. Escape sequences are visualized with
+// [Visualize], so the golden files and the differences are readable.
+//
+// Run the tests with COLORPROFILE_UPDATE=1 to write the golden files
+// instead:
+//
+//	COLORPROFILE_UPDATE=1 go test ./mypkg
+//
+// The -update flag works too if the test binary defines it, e.g. with
+// x/exp/golden, or with:
+//
+//	var _ = flag.Bool("update", false, "update the golden files")
+func AssertGolden(tb testing.TB, input string) {
+	tb.Helper()
+
+	got := renderGolden(input)
+	name := filepath.Join("testdata", filepath.FromSlash(tb.Name())+".golden")
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil { //nolint:mnd
+			tb.Fatalf("can't create the golden file directory: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(got), 0o644); err != nil { //nolint:mnd,gosec
+			tb.Fatalf("can't write the golden file: %v", err)
+		}
+		return
+	}
+
+	b, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		tb.Fatalf("missing golden file %s, run the tests with -update or %s=1 to create it", name, UpdateEnv)
+	}
+	if err != nil {
+		tb.Fatalf("can't read the golden file: %v", err)
+	}
+
+	// Golden files may be checked out with CRLF line endings on Windows.
+	expected := strings.ReplaceAll(string(b), "\r\n", "\n")
+	if got != expected {
+		tb.Errorf("output doesn't match %s (-expected +got):\n%s", name, diffLines(expected, got))
+	}
+}
+
+// renderGolden returns the golden file content for input.
+func renderGolden(input string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- input --\n%s\n", Visualize(input))
+	for _, p := range goldenProfiles {
+		var out bytes.Buffer
+		w := &colorprofile.Writer{Forward: &out, Profile: p}
+		_, _ = w.WriteString(input)
+		name, _ := p.MarshalText()
+		fmt.Fprintf(&b, "-- %s --\n%s\n", name, Visualize(out.String()))
+	}
+	return b.String()
+}
+
+// Visualize returns s with the escape sequences replaced by readable
+// descriptions between ⟨ and ⟩. Styles are described by their attributes,
+// e.g. "\x1b[1;38;2;107;80;255m" becomes "⟨bold fg:#6b50ff⟩", and other
+// sequences and control characters are quoted, e.g. "⟨\x1b[2J⟩". Newlines
+// and tabs are kept as is.
+func Visualize(s string) string {
+	parser := ansi.GetParser()
+	defer ansi.PutParser(parser)
+
+	var b strings.Builder
+	var state byte
+	for len(s) > 0 {
+		parser.Reset()
+		seq, _, n, newState := ansi.DecodeSequence(s, state, parser)
+		switch {
+		case ansi.HasCsiPrefix(seq) && parser.Command() == 'm':
+			b.WriteString("⟨" + describeStyle(parser.Params()) + "⟩")
+		case len(seq) == 1 && seq[0] < ' ' && seq[0] != '\n' && seq[0] != '\t',
+			len(seq) > 0 && (seq[0] == ansi.ESC || seq[0] == ansi.DEL || seq[0] >= 0x80 && seq[0] < 0xa0):
+			b.WriteString("⟨" + strings.Trim(strconv.Quote(seq), `"`) + "⟩")
+		default:
+			b.WriteString(seq)
+		}
+		s = s[n:]
+		state = newState
+	}
+	return b.String()
+}
+
+// sgrNames are the names of the SGR attributes without parameters.
+var sgrNames = map[int]string{
+	1:  "bold",
+	2:  "faint",
+	3:  "italic",
+	4:  "underline",
+	5:  "blink",
+	6:  "rapid-blink",
+	7:  "reverse",
+	8:  "conceal",
+	9:  "strike",
+	21: "double-underline",
+	22: "normal-intensity",
+	23: "no-italic",
+	24: "no-underline",
+	25: "no-blink",
+	27: "no-reverse",
+	28: "no-conceal",
+	29: "no-strike",
+	39: "fg:default",
+	49: "bg:default",
+	53: "overline",
+	55: "no-overline",
+	59: "ul:default",
+}
+
+// basicColorNames are the names of the 16 basic colors.
+var basicColorNames = []string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"bright-black", "bright-red", "bright-green", "bright-yellow",
+	"bright-blue", "bright-magenta", "bright-cyan", "bright-white",
+}
+
+// describeStyle describes the attributes of an SGR sequence.
+func describeStyle(params ansi.Params) string {
+	if len(params) == 0 {
+		return "reset"
+	}
+
+	attrs := make([]string, 0, len(params))
+	for i := 0; i < len(params); i++ {
+		param := params[i].Param(0)
+		switch {
+		case param == 0:
+			attrs = append(attrs, "reset")
+		case param == 4 && params[i].HasMore() && i+1 < len(params):
+			// Underline style, e.g. 4:3 for curly underlines.
+			i++
+			attrs = append(attrs, "underline:"+strconv.Itoa(params[i].Param(0)))
+		case param >= 30 && param <= 37:
+			attrs = append(attrs, "fg:"+basicColorNames[param-30])
+		case param >= 40 && param <= 47:
+			attrs = append(attrs, "bg:"+basicColorNames[param-40])
+		case param >= 90 && param <= 97:
+			attrs = append(attrs, "fg:"+basicColorNames[param-90+8])
+		case param >= 100 && param <= 107:
+			attrs = append(attrs, "bg:"+basicColorNames[param-100+8])
+		case param == 38 || param == 48 || param == 58:
+			prefix := map[int]string{38: "fg:", 48: "bg:", 58: "ul:"}[param]
+			var c color.Color
+			n := ansi.ReadStyleColor(params[i:], &c)
+			if n == 0 {
+				attrs = append(attrs, prefix+"invalid")
+				continue
+			}
+			i += n - 1
+			attrs = append(attrs, prefix+describeColor(c))
+		default:
+			name, ok := sgrNames[param]
+			if !ok {
+				name = "sgr:" + strconv.Itoa(param)
+			}
+			attrs = append(attrs, name)
+		}
+	}
+
+	return strings.Join(attrs, " ")
+}
+
+// describeColor describes a color read from an SGR sequence.
+func describeColor(c color.Color) string {
+	switch c := c.(type) {
+	case nil:
+		return "none"
+	case ansi.BasicColor:
+		if int(c) < len(basicColorNames) {
+			return basicColorNames[c]
+		}
+		return strconv.Itoa(int(c))
+	case ansi.IndexedColor:
+		return strconv.Itoa(int(c))
+	default:
+		r, g, b, a := c.RGBA()
+		if a == 0 {
+			return "transparent"
+		}
+		return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8) //nolint:mnd
+	}
+}
+
+// diffLines returns a line diff of a and b. Removed lines are prefixed with
+// "-", added ones with "+", and the -- section -- headers of golden files are
+// kept for context.
+func diffLines(a, b string) string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			if strings.HasPrefix(x[i], "-- ") {
+				out.WriteString("  " + x[i] + "\n")
+			}
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] >= lcs[i+1][j]):
+			out.WriteString("+ " + y[j] + "\n")
+			j++
+		default:
+			out.WriteString("- " + x[i] + "\n")
+			i++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,117 @@
+package colorprofiletest
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The package doesn't define -update itself, so test binaries can, like
+// other golden file packages do.
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestVisualize(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"plain", "hello\tworld\n", "hello\tworld\n"},
+		{"reset", "\x1b[m\x1b[0m", "⟨reset⟩⟨reset⟩"},
+		{"attributes", "\x1b[1;3;4:3;9m", "⟨bold italic underline:3 strike⟩"},
+		{"basic colors", "\x1b[31;42;91;102m", "⟨fg:red bg:green fg:bright-red bg:bright-green⟩"},
+		{"256 colors", "\x1b[38;5;196;48:5:21m", "⟨fg:196 bg:21⟩"},
+		{"true colors", "\x1b[38;2;107;80;255;58:2::1:2:3m", "⟨fg:#6b50ff ul:#010203⟩"},
+		{"default colors", "\x1b[39;49;59m", "⟨fg:default bg:default ul:default⟩"},
+		{"unknown attribute", "\x1b[73m", "⟨sgr:73⟩"},
+		{"invalid color", "\x1b[38;2;1m", "⟨fg:invalid faint bold⟩"},
+		{"other sequences", "\x1b[2J\x1b]8;;https://charm.sh\x07", `⟨\x1b[2J⟩⟨\x1b]8;;https://charm.sh\a⟩`},
+		{"control characters", "a\rb\x07", `a⟨\r⟩b⟨\a⟩`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Visualize(tc.input); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	t.Run("styles", func(t *testing.T) {
+		AssertGolden(t, "hello \x1b[1;38;2;107;80;255mworld\x1b[m\n\x1b[48;5;196mbye\x1b[0m")
+	})
+	t.Run("plain", func(t *testing.T) {
+		AssertGolden(t, "hello world")
+	})
+}
+
+// recorder is a testing.TB recording failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	failed  bool
+	message string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failed = true
+	r.message = fmt.Sprintf(format, args...)
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+// TestAssertGoldenMismatch compares with a stale golden file, as if the
+// Writer didn't downsample true colors to ANSI256.
+func TestAssertGoldenMismatch(t *testing.T) {
+	if updating() {
+		t.Skip("not comparing golden files")
+	}
+
+	r := &recorder{TB: t}
+	AssertGolden(r, "hello \x1b[38;2;107;80;255mworld\x1b[m")
+	if !r.failed {
+		t.Fatal("expected a mismatch")
+	}
+	for _, want := range []string{
+		"testdata",
+		"  -- ansi256 --",
+		"- hello ⟨fg:#6b50ff⟩world⟨reset⟩",
+		"+ hello ⟨fg:63⟩world⟨reset⟩",
+	} {
+		if !strings.Contains(r.message, want) {
+			t.Errorf("expected the message to contain %q, got:\n%s", want, r.message)
+		}
+	}
+}
+
+func TestUpdating(t *testing.T) {
+	if *update {
+		t.Skip("updating golden files")
+	}
+
+	t.Setenv(UpdateEnv, "")
+	if updating() {
+		t.Error("expected not to update by default")
+	}
+
+	t.Setenv(UpdateEnv, "1")
+	if !updating() {
+		t.Errorf("expected %s=1 to update", UpdateEnv)
+	}
+
+	t.Setenv(UpdateEnv, "")
+	if err := flag.Set("update", "true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { *update = false })
+	if !updating() {
+		t.Error("expected -update to update")
+	}
+}
@@ -0,0 +1,167 @@
+package colorprofiletest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Terminal is a fake terminal answering the queries written to it, like a
+// real terminal emulator would. It answers primary device attributes (DA1),
+// XTGETTCAP, and OSC 4, 10, and 11 color queries. The zero value answers DA1
+// only.
+//
+// Terminal implements [io.ReadWriter], so it can be used as
+// [colorprofile.Detector.TTY]. It's safe for concurrent use.
+type Terminal struct {
+	// Attributes are the primary device attributes the terminal reports. If
+	// empty, it reports a VT220 with ANSI colors, i.e. 62 and 22.
+	Attributes []int
+
+	// Caps are the terminfo capabilities the terminal reports to XTGETTCAP
+	// queries, keyed by name. Boolean capabilities such as "RGB" or "Tc"
+	// have an empty value.
+	Caps map[string]string
+
+	// Foreground and Background are the default colors the terminal reports
+	// to OSC 10 and 11 queries. If nil, the queries aren't answered.
+	Foreground, Background color.Color
+
+	// Palette are the indexed colors the terminal reports to OSC 4 queries.
+	Palette map[int]color.Color
+
+	mu      sync.Mutex
+	queries []string
+	replies bytes.Buffer
+}
+
+// Write parses the queries in p and queues the replies.
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parser := ansi.GetParser()
+	defer ansi.PutParser(parser)
+
+	var state byte
+	b := p
+	for len(b) > 0 {
+		parser.Reset()
+		seq, _, n, newState := ansi.DecodeSequence(b, state, parser)
+		if bytes.HasPrefix(seq, []byte{ansi.ESC}) {
+			t.answer(string(seq), ansi.Cmd(parser.Command()))
+		}
+		b = b[n:]
+		state = newState
+	}
+
+	return len(p), nil
+}
+
+// Read reads the queued replies. It returns [io.EOF] when there are none,
+// where a real terminal would block.
+func (t *Terminal) Read(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.replies.Read(p) //nolint:wrapcheck
+}
+
+// Queries returns the queries the terminal received so far, in order.
+func (t *Terminal) Queries() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.queries...)
+}
+
+// answer queues the reply to a query.
+func (t *Terminal) answer(seq string, cmd ansi.Cmd) {
+	switch {
+	case ansi.HasCsiPrefix([]byte(seq)) && cmd.Final() == 'c' && cmd.Prefix() == 0:
+		t.queries = append(t.queries, seq)
+		attrs := t.Attributes
+		if len(attrs) == 0 {
+			attrs = []int{62, 22}
+		}
+		t.replies.WriteString(ansi.PrimaryDeviceAttributes(attrs...))
+
+	case ansi.HasDcsPrefix([]byte(seq)) && cmd.Intermediate() == '+' && cmd.Final() == 'q':
+		t.queries = append(t.queries, seq)
+		for _, name := range strings.Split(stringData(seq, "+q"), ";") {
+			t.answerTermcap(name)
+		}
+
+	case ansi.HasOscPrefix([]byte(seq)):
+		data := stringData(seq, "]")
+		if !strings.HasSuffix(data, ";?") {
+			return
+		}
+		t.queries = append(t.queries, seq)
+		t.answerColor(strings.TrimSuffix(data, ";?"))
+	}
+}
+
+// answerTermcap queues the reply to an XTGETTCAP query of a hex encoded
+// capability name.
+func (t *Terminal) answerTermcap(name string) {
+	b, err := hex.DecodeString(name)
+	if err != nil {
+		return
+	}
+	v, ok := t.Caps[string(b)]
+	if !ok {
+		t.replies.WriteString("\x1bP0+r" + name + "\x1b\\")
+		return
+	}
+	reply := "\x1bP1+r" + name
+	if len(v) > 0 {
+		reply += "=" + strings.ToUpper(hex.EncodeToString([]byte(v)))
+	}
+	t.replies.WriteString(reply + "\x1b\\")
+}
+
+// answerColor queues the reply to an OSC color query, e.g. "11" or "4;1".
+func (t *Terminal) answerColor(query string) {
+	var c color.Color
+	switch query {
+	case "10":
+		c = t.Foreground
+	case "11":
+		c = t.Background
+	default:
+		index, ok := strings.CutPrefix(query, "4;")
+		if !ok {
+			return
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			return
+		}
+		c = t.Palette[i]
+	}
+	if c == nil {
+		return
+	}
+	t.replies.WriteString("\x1b]" + query + ";" + ansi.XRGBColor{Color: c}.String() + "\x1b\\")
+}
+
+// stringData returns the data of a DCS or OSC sequence after prefix, without
+// the string terminator.
+func stringData(seq, prefix string) string {
+	if i := strings.Index(seq, prefix); i >= 0 {
+		seq = seq[i+len(prefix):]
+	}
+	for _, st := range []string{"\x1b\\", "\x07", "\x9c"} {
+		if s, ok := strings.CutSuffix(seq, st); ok {
+			return s
+		}
+	}
+	return seq
+}
+
+var _ io.ReadWriter = (*Terminal)(nil)
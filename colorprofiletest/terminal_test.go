@@ -0,0 +1,85 @@
+package colorprofiletest
+
+import (
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestTerminal(t *testing.T) {
+	cases := []struct {
+		name     string
+		terminal *Terminal
+		query    string
+		expected string
+	}{
+		{
+			name:     "DA1",
+			terminal: &Terminal{},
+			query:    ansi.RequestPrimaryDeviceAttributes,
+			expected: "\x1b[?62;22c",
+		},
+		{
+			name:     "DA1 attributes",
+			terminal: &Terminal{Attributes: []int{6}},
+			query:    ansi.RequestPrimaryDeviceAttributes,
+			expected: "\x1b[?6c",
+		},
+		{
+			name:     "XTGETTCAP",
+			terminal: &Terminal{Caps: map[string]string{"RGB": "", "TN": "xterm"}},
+			query:    ansi.XTGETTCAP("RGB", "Tc", "TN"),
+			expected: "\x1bP1+r524742\x1b\\\x1bP0+r5463\x1b\\\x1bP1+r544E=787465726D\x1b\\",
+		},
+		{
+			name:     "background",
+			terminal: &Terminal{Background: color.RGBA{0x1e, 0x1e, 0x2e, 0xff}},
+			query:    ansi.RequestBackgroundColor,
+			expected: "\x1b]11;rgb:1e1e/1e1e/2e2e\x1b\\",
+		},
+		{
+			name:     "unknown foreground",
+			terminal: &Terminal{},
+			query:    ansi.RequestForegroundColor,
+			expected: "",
+		},
+		{
+			name:     "palette",
+			terminal: &Terminal{Palette: map[int]color.Color{1: color.RGBA{0xff, 0, 0, 0xff}}},
+			query:    "\x1b]4;1;?\x1b\\\x1b]4;2;?\x07",
+			expected: "\x1b]4;1;rgb:ffff/0000/0000\x1b\\",
+		},
+		{
+			name:     "not a query",
+			terminal: &Terminal{},
+			query:    "hello \x1b[31mworld\x1b[m\x1b]11;#000000\x07",
+			expected: "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := io.WriteString(tc.terminal, tc.query); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			reply, err := io.ReadAll(tc.terminal)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(reply) != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, reply)
+			}
+		})
+	}
+}
+
+func TestTerminalQueries(t *testing.T) {
+	var term Terminal
+	io.WriteString(&term, "hello"+ansi.XTGETTCAP("RGB")+ansi.RequestPrimaryDeviceAttributes) //nolint:errcheck
+	queries := term.Queries()
+	if len(queries) != 2 || queries[1] != ansi.RequestPrimaryDeviceAttributes {
+		t.Errorf("expected the XTGETTCAP and DA1 queries, got %q", queries)
+	}
+}
@@ -0,0 +1,81 @@
+package colorprofile
+
+import (
+	"path"
+	"strings"
+)
+
+// Config is a user configuration correcting the color profile detection of
+// terminals the built-in rules get wrong. It's usually loaded from
+// $XDG_CONFIG_HOME/colorprofile/config.toml with the
+// github.com/charmbracelet/colorprofile/config package:
+//
+//	cfg, err := config.LoadUser(os.DirFS("/"), os.Environ())
+//
+// See [Detector.Config].
+type Config struct {
+	// Terminals are the terminal overrides. They're matched in order and the
+	// first match wins.
+	Terminals []TerminalOverride
+}
+
+// TerminalOverride overrides the color profile of the terminals matching TERM
+// and TERM_PROGRAM patterns. The patterns use the [path.Match] syntax, e.g.
+// "xterm-*". All the non-empty patterns have to match.
+type TerminalOverride struct {
+	// Term is the pattern matching TERM.
+	Term string
+	// Program is the pattern matching TERM_PROGRAM.
+	Program string
+	// Profile is the color profile the terminal supports. It replaces the
+	// detected one, even if it's lower.
+	Profile Profile
+	// IgnoreColorTerm ignores COLORTERM for the terminal. It only applies
+	// when Profile isn't set.
+	IgnoreColorTerm bool
+}
+
+// String returns a description of the override patterns.
+func (o TerminalOverride) String() string {
+	var parts []string
+	if len(o.Term) > 0 {
+		parts = append(parts, "TERM="+o.Term)
+	}
+	if len(o.Program) > 0 {
+		parts = append(parts, "TERM_PROGRAM="+o.Program)
+	}
+	return strings.Join(parts, " and ")
+}
+
+// match reports whether the override matches the given environment.
+func (o TerminalOverride) match(env environ) bool {
+	if len(o.Term) == 0 && len(o.Program) == 0 {
+		return false
+	}
+	for _, m := range []struct{ pattern, key string }{
+		{o.Term, "TERM"},
+		{o.Program, "TERM_PROGRAM"},
+	} {
+		if len(m.pattern) == 0 {
+			continue
+		}
+		if ok, _ := path.Match(m.pattern, env.get(m.key)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the first terminal override matching the environment. It's
+// safe to call on a nil configuration.
+func (c *Config) lookup(env environ) (TerminalOverride, bool) {
+	if c == nil {
+		return TerminalOverride{}, false
+	}
+	for _, o := range c.Terminals {
+		if o.match(env) {
+			return o, true
+		}
+	}
+	return TerminalOverride{}, false
+}
@@ -0,0 +1,146 @@
+// Package config loads the user configuration files correcting the color
+// profile detection of terminals, e.g.
+// $XDG_CONFIG_HOME/colorprofile/config.toml:
+//
+//	# Misdetected as ANSI256.
+//	[[terminal]]
+//	term = "xterm-256color"
+//	term_program = "MyTerm"
+//	profile = "truecolor"
+//
+//	# Inherits COLORTERM=truecolor but doesn't support it, the detection
+//	# without it is right.
+//	[[terminal]]
+//	term = "vt*"
+//	ignore_colorterm = true
+//
+// The loaded configuration is used with [colorprofile.Detector.Config]:
+//
+//	cfg, err := config.LoadUser(os.DirFS("/"), os.Environ())
+//	if err != nil {
+//		log.Printf("invalid color configuration: %v", err)
+//	}
+//
+//	d := colorprofile.Detector{Config: cfg}
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/colorprofile"
+)
+
+// file is the layout of a configuration file.
+type file struct {
+	Terminals []terminal `toml:"terminal"`
+}
+
+// terminal is a terminal override in a configuration file. See
+// [colorprofile.TerminalOverride].
+type terminal struct {
+	Term            string               `toml:"term"`
+	Program         string               `toml:"term_program"`
+	Profile         colorprofile.Profile `toml:"profile"`
+	IgnoreColorTerm bool                 `toml:"ignore_colorterm"`
+}
+
+// UserPath returns the path of the user configuration file based on the
+// given environment variables: $XDG_CONFIG_HOME/colorprofile/config.toml,
+// falling back to $HOME/.config, or %APPDATA% on Windows. It returns an empty
+// string if none of them are set.
+func UserPath(env []string) string {
+	const name = "colorprofile/config.toml"
+	if dir := getenv(env, "XDG_CONFIG_HOME"); len(dir) > 0 {
+		return path.Join(dir, name)
+	}
+	if home := getenv(env, "HOME"); len(home) > 0 {
+		return path.Join(home, ".config", name)
+	}
+	if dir := getenv(env, "APPDATA"); len(dir) > 0 {
+		return path.Join(strings.ReplaceAll(dir, `\`, "/"), name)
+	}
+	return ""
+}
+
+// getenv returns the value of the last key=value environment variable with
+// the given key, or an empty string.
+func getenv(env []string, key string) string {
+	var value string
+	for _, e := range env {
+		if k, v, ok := strings.Cut(e, "="); ok && k == key {
+			value = v
+		}
+	}
+	return value
+}
+
+// LoadUser loads the user configuration file at [UserPath] from fsys,
+// usually os.DirFS("/"). Absolute paths are made relative to the root of
+// fsys. A missing configuration file isn't an error: LoadUser returns nil and
+// no error.
+func LoadUser(fsys fs.FS, env []string) (*colorprofile.Config, error) {
+	name := UserPath(env)
+	if len(name) == 0 {
+		return nil, nil
+	}
+
+	// fs.FS paths are unrooted, and Windows paths start with a volume name.
+	name = strings.TrimPrefix(name, "/")
+	if i := strings.Index(name, ":/"); i >= 0 {
+		name = name[i+2:]
+	}
+
+	c, err := Load(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return c, err
+}
+
+// Load loads the configuration file with the given name from fsys.
+func Load(fsys fs.FS, name string) (*colorprofile.Config, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	var f file
+	md, err := toml.Decode(string(b), &f)
+	if err != nil {
+		return nil, fmt.Errorf("colorprofile: %s: %w", name, err)
+	}
+	if keys := md.Undecoded(); len(keys) > 0 {
+		return nil, fmt.Errorf("colorprofile: %s: unknown key %s", name, keys[0])
+	}
+
+	var c colorprofile.Config
+	for i, t := range f.Terminals {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("colorprofile: %s: terminal %d: %w", name, i+1, err)
+		}
+		c.Terminals = append(c.Terminals, colorprofile.TerminalOverride(t))
+	}
+
+	return &c, nil
+}
+
+// validate reports whether the override is well-formed.
+func (t terminal) validate() error {
+	if len(t.Term) == 0 && len(t.Program) == 0 {
+		return errors.New("term or term_program is required")
+	}
+	for _, pattern := range []string{t.Term, t.Program} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if t.Profile == colorprofile.Unknown && !t.IgnoreColorTerm {
+		return errors.New("profile or ignore_colorterm is required")
+	}
+	return nil
+}
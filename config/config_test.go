@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/charmbracelet/colorprofile"
+)
+
+const testConfig = `
+[[terminal]]
+term = "xterm-256color"
+term_program = "MyTerm"
+profile = "truecolor"
+
+[[terminal]]
+term = "vt*"
+profile = "ascii"
+
+[[terminal]]
+term_program = "LiarTerm"
+ignore_colorterm = true
+`
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.toml":   {Data: []byte(testConfig)},
+		"syntax.toml":   {Data: []byte("[[terminal]\n")},
+		"unknown.toml":  {Data: []byte("[[terminal]]\nterm = \"xterm\"\ncolors = 256\n")},
+		"profile.toml":  {Data: []byte("[[terminal]]\nterm = \"xterm\"\nprofile = \"lots\"\n")},
+		"pattern.toml":  {Data: []byte("[[terminal]]\nterm = \"xterm[\"\nprofile = \"ansi\"\n")},
+		"criteria.toml": {Data: []byte("[[terminal]]\nprofile = \"ansi\"\n")},
+		"empty.toml":    {Data: []byte("[[terminal]]\nterm = \"xterm\"\n")},
+	}
+
+	c, err := Load(fsys, "config.toml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []colorprofile.TerminalOverride{
+		{Term: "xterm-256color", Program: "MyTerm", Profile: colorprofile.TrueColor},
+		{Term: "vt*", Profile: colorprofile.ASCII},
+		{Program: "LiarTerm", IgnoreColorTerm: true},
+	}
+	if len(c.Terminals) != len(expected) {
+		t.Fatalf("expected %d terminals, got %d", len(expected), len(c.Terminals))
+	}
+	for i, o := range c.Terminals {
+		if o != expected[i] {
+			t.Errorf("terminal %d: expected %+v, got %+v", i, expected[i], o)
+		}
+	}
+
+	for _, name := range []string{
+		"syntax.toml",
+		"unknown.toml",
+		"profile.toml",
+		"pattern.toml",
+		"criteria.toml",
+		"empty.toml",
+	} {
+		if _, err := Load(fsys, name); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := Load(fsys, "missing.toml"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestUserPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		environ  []string
+		expected string
+	}{
+		{"none", nil, ""},
+		{"home", []string{"HOME=/home/u"}, "/home/u/.config/colorprofile/config.toml"},
+		{"xdg", []string{"HOME=/home/u", "XDG_CONFIG_HOME=/cfg"}, "/cfg/colorprofile/config.toml"},
+		{"appdata", []string{`APPDATA=C:\Users\u\AppData\Roaming`}, "C:/Users/u/AppData/Roaming/colorprofile/config.toml"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := UserPath(tc.environ); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadUser(t *testing.T) {
+	fsys := fstest.MapFS{
+		"home/u/.config/colorprofile/config.toml":          {Data: []byte(testConfig)},
+		"Users/u/AppData/Roaming/colorprofile/config.toml": {Data: []byte(testConfig)},
+	}
+
+	for _, env := range [][]string{
+		{"HOME=/home/u"},
+		{`APPDATA=C:\Users\u\AppData\Roaming`},
+	} {
+		c, err := LoadUser(fsys, env)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", env, err)
+		}
+		if c == nil || len(c.Terminals) != 3 {
+			t.Errorf("%v: expected the test config, got %+v", env, c)
+		}
+	}
+
+	for _, env := range [][]string{nil, {"HOME=/home/v"}} {
+		c, err := LoadUser(fsys, env)
+		if c != nil || err != nil {
+			t.Errorf("%v: expected no config and no error, got %+v, %v", env, c, err)
+		}
+	}
+}
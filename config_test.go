@@ -0,0 +1,80 @@
+package colorprofile
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectorConfig(t *testing.T) {
+	c := &Config{Terminals: []TerminalOverride{
+		{Term: "xterm-256color", Program: "MyTerm", Profile: TrueColor},
+		{Term: "vt*", Profile: ASCII},
+		{Program: "LiarTerm", IgnoreColorTerm: true},
+	}}
+
+	cases := []struct {
+		name     string
+		environ  []string
+		expected Profile
+	}{
+		{
+			name:     "upgrade",
+			environ:  []string{"TERM=xterm-256color", "TERM_PROGRAM=MyTerm"},
+			expected: TrueColor,
+		},
+		{
+			name:     "other program",
+			environ:  []string{"TERM=xterm-256color", "TERM_PROGRAM=OtherTerm"},
+			expected: ANSI256,
+		},
+		{
+			name:     "downgrade",
+			environ:  []string{"TERM=vt340", "COLORTERM=truecolor"},
+			expected: ASCII,
+		},
+		{
+			name:     "ignore COLORTERM",
+			environ:  []string{"TERM=xterm-256color", "TERM_PROGRAM=LiarTerm", "COLORTERM=truecolor"},
+			expected: ANSI256,
+		},
+		{
+			name:     "NO_COLOR",
+			environ:  []string{"TERM=xterm-256color", "TERM_PROGRAM=MyTerm", "NO_COLOR=1"},
+			expected: ASCII,
+		},
+		{
+			name:     "FORCE_COLOR",
+			environ:  []string{"TERM=vt340", "FORCE_COLOR=2"},
+			expected: ANSI256,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Detector{Config: c}
+			if p := d.Env(tc.environ); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+
+	var d Detector
+	if p := d.Env([]string{"TERM=vt340", "COLORTERM=truecolor"}); p != TrueColor {
+		t.Errorf("expected TrueColor without a config, got %v", p)
+	}
+}
+
+func TestExplainConfig(t *testing.T) {
+	c := &Config{Terminals: []TerminalOverride{{Term: "xterm-*", Profile: ANSI256}}}
+	e := (&Detector{Config: c}).Explain(io.Discard, []string{
+		"TERM=xterm-kitty",
+		"TTY_FORCE=1",
+	})
+	if e.Profile != ANSI256 {
+		t.Errorf("expected ANSI256, got %v", e.Profile)
+	}
+	if want := "config file overrides TERM=xterm-*"; !strings.Contains(e.String(), want) {
+		t.Errorf("expected explanation to mention %q, got:\n%s", want, e)
+	}
+}
@@ -0,0 +1,167 @@
+package colorprofile
+
+import (
+	"errors"
+	"image/color"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+)
+
+// enableVirtualTerminalProcessing is the Windows console mode flag making
+// the console process VT escape sequences.
+const enableVirtualTerminalProcessing uint32 = 0x0004
+
+// Console is the output handle of a Windows console. It's an interface so the
+// console logic can be tested on any operating system.
+type Console interface {
+	// Mode returns the console mode.
+	Mode() (uint32, error)
+	// SetMode sets the console mode.
+	SetMode(mode uint32) error
+}
+
+// ConsoleState describes the escape sequence support of a Windows console.
+type ConsoleState struct {
+	// VT tells whether the console processes VT escape sequences, i.e.
+	// ENABLE_VIRTUAL_TERMINAL_PROCESSING is set.
+	VT bool
+	// Enabled tells whether VT processing was enabled by probing the
+	// console.
+	Enabled bool
+	// Legacy tells whether the console doesn't process VT escape sequences,
+	// either because it can't or because it wasn't asked to. Colors then
+	// have to be set with console attributes, which only support the 16
+	// ANSI colors.
+	Legacy bool
+	// Profile is the color profile the console supports. It's ANSI for
+	// legacy consoles, with console attributes.
+	Profile Profile
+}
+
+// ProbeConsole inspects the mode of a Windows console, and enables VT
+// processing if it's not enabled and enable is true. major and build are the
+// Windows NT major version and build number: VT processing is only available
+// from Windows 10 build 10586, and true colors from build 14931.
+//
+// It returns an error if c isn't a console, e.g. when the output is
+// redirected.
+func ProbeConsole(c Console, enable bool, major, build uint32) (ConsoleState, error) {
+	mode, err := c.Mode()
+	if err != nil {
+		return ConsoleState{}, err //nolint:wrapcheck
+	}
+
+	var state ConsoleState
+	switch {
+	case mode&enableVirtualTerminalProcessing != 0:
+		state.VT = true
+	case enable && major >= 10 && build >= 10586:
+		if err := c.SetMode(mode | enableVirtualTerminalProcessing); err == nil {
+			state.VT = true
+			state.Enabled = true
+		}
+	}
+
+	switch {
+	case !state.VT:
+		state.Legacy = true
+		state.Profile = ANSI
+	case build < 14931:
+		// No true color support before build 14931
+		state.Profile = ANSI256
+	default:
+		state.Profile = TrueColor
+	}
+
+	return state, nil
+}
+
+// WindowsConsole probes the Windows console f refers to, and enables VT
+// processing if enable is true. See [ProbeConsole]. It returns an error if f
+// isn't a console, or on other operating systems.
+func WindowsConsole(f term.File, enable bool) (ConsoleState, error) {
+	c, err := openConsole(f.Fd())
+	if err != nil {
+		return ConsoleState{}, err
+	}
+	major, build := windowsVersion()
+	return ProbeConsole(c, enable, major, build)
+}
+
+// errNotConsole is returned when the output isn't a Windows console.
+var errNotConsole = errors.New("not a Windows console")
+
+// ConsoleAttributes are the text attributes of a legacy Windows console,
+// which doesn't process VT escape sequences. See [Output.Console].
+type ConsoleAttributes struct {
+	// Foreground and Background are the text colors. They're one of the 16
+	// [ansi.BasicColor] colors, or nil for the console defaults.
+	Foreground, Background color.Color
+	// Bold tells whether the text is bold, which legacy consoles render
+	// with intense foreground colors.
+	Bold bool
+	// Reverse tells whether the foreground and background colors are
+	// swapped.
+	Reverse bool
+}
+
+// ConsoleSink receives the text attributes a [Writer] translates SGR
+// sequences into for legacy Windows consoles. The console returned by
+// [Detector.OpenConsole] is used as sink if it implements ConsoleSink, which
+// the real Windows consoles do with SetConsoleTextAttribute.
+type ConsoleSink interface {
+	// SetAttributes sets the attributes of the text written next.
+	SetAttributes(attrs ConsoleAttributes) error
+}
+
+// Legacy Windows console character attributes.
+const (
+	consoleBlue      uint16 = 0x1
+	consoleGreen     uint16 = 0x2
+	consoleRed       uint16 = 0x4
+	consoleIntensity uint16 = 0x8
+)
+
+// consoleColors are the Windows console colors of the 8 basic ANSI colors,
+// which order the red, green, and blue bits differently.
+var consoleColors = [8]uint16{
+	0,
+	consoleRed,
+	consoleGreen,
+	consoleRed | consoleGreen,
+	consoleBlue,
+	consoleRed | consoleBlue,
+	consoleGreen | consoleBlue,
+	consoleRed | consoleGreen | consoleBlue,
+}
+
+// consoleTextAttribute returns the Windows console character attribute of
+// attrs. defaults is the attribute the console started with, which gives the
+// default colors.
+func consoleTextAttribute(attrs ConsoleAttributes, defaults uint16) uint16 {
+	fg := defaults & 0xf                                           //nolint:mnd
+	bg := defaults >> 4 & 0xf                                      //nolint:mnd
+	if c, ok := attrs.Foreground.(ansi.BasicColor); ok && c < 16 { //nolint:mnd
+		fg = consoleColor(c)
+	}
+	if c, ok := attrs.Background.(ansi.BasicColor); ok && c < 16 { //nolint:mnd
+		bg = consoleColor(c)
+	}
+	if attrs.Bold {
+		fg |= consoleIntensity
+	}
+	if attrs.Reverse {
+		fg, bg = bg, fg
+	}
+	return defaults&^0xff | bg<<4 | fg //nolint:mnd
+}
+
+// consoleColor returns the Windows console color of a basic ANSI color.
+func consoleColor(c ansi.BasicColor) uint16 {
+	attr := consoleColors[c%8]
+	if c >= 8 { //nolint:mnd
+		attr |= consoleIntensity
+	}
+	return attr
+}
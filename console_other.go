@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package colorprofile
+
+// openConsole returns an error since there are no Windows consoles on other
+// operating systems.
+func openConsole(uintptr) (Console, error) {
+	return nil, errNotConsole
+}
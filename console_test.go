@@ -0,0 +1,264 @@
+package colorprofile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// fakeConsole is a Windows console with a made up mode.
+type fakeConsole struct {
+	mode    uint32
+	err     error
+	setErr  error
+	setMode bool
+}
+
+func (c *fakeConsole) Mode() (uint32, error) { return c.mode, c.err }
+
+func (c *fakeConsole) SetMode(mode uint32) error {
+	if c.setErr != nil {
+		return c.setErr
+	}
+	c.mode = mode
+	c.setMode = true
+	return nil
+}
+
+func TestProbeConsole(t *testing.T) {
+	errUnsupported := errors.New("unsupported")
+	cases := []struct {
+		name         string
+		console      fakeConsole
+		enable       bool
+		major, build uint32
+		expected     ConsoleState
+		set          bool
+	}{
+		{
+			name:     "VT enabled",
+			console:  fakeConsole{mode: 0x7},
+			major:    10,
+			build:    22631,
+			expected: ConsoleState{VT: true, Profile: TrueColor},
+		},
+		{
+			name:     "VT enabled before true colors",
+			console:  fakeConsole{mode: 0x7},
+			major:    10,
+			build:    10586,
+			expected: ConsoleState{VT: true, Profile: ANSI256},
+		},
+		{
+			name:     "VT disabled",
+			console:  fakeConsole{mode: 0x3},
+			major:    10,
+			build:    22631,
+			expected: ConsoleState{Legacy: true, Profile: ANSI},
+		},
+		{
+			name:     "enable VT",
+			console:  fakeConsole{mode: 0x3},
+			enable:   true,
+			major:    10,
+			build:    22631,
+			expected: ConsoleState{VT: true, Enabled: true, Profile: TrueColor},
+			set:      true,
+		},
+		{
+			name:     "enable VT fails",
+			console:  fakeConsole{mode: 0x3, setErr: errUnsupported},
+			enable:   true,
+			major:    10,
+			build:    22631,
+			expected: ConsoleState{Legacy: true, Profile: ANSI},
+		},
+		{
+			name:     "enable VT before Windows 10",
+			console:  fakeConsole{mode: 0x3},
+			enable:   true,
+			major:    6,
+			build:    7601,
+			expected: ConsoleState{Legacy: true, Profile: ANSI},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := tc.console
+			state, err := ProbeConsole(&c, tc.enable, tc.major, tc.build)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if state != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, state)
+			}
+			if c.setMode != tc.set {
+				t.Errorf("expected mode set %v, got %v", tc.set, c.setMode)
+			}
+			if c.setMode && c.mode != 0x7 {
+				t.Errorf("expected mode 0x7, got %#x", c.mode)
+			}
+		})
+	}
+
+	if _, err := ProbeConsole(&fakeConsole{err: errUnsupported}, true, 10, 22631); err == nil {
+		t.Error("expected an error for a non-console handle")
+	}
+}
+
+func TestDetectorConsole(t *testing.T) {
+	cases := []struct {
+		name     string
+		mode     uint32
+		enable   bool
+		environ  []string
+		expected Profile
+	}{
+		{"VT enabled", 0x7, false, nil, TrueColor},
+		{"VT disabled", 0x3, false, nil, TrueColor},
+		{"VT disabled, enable", 0x3, true, nil, TrueColor},
+		{"VT disabled, ANSICON", 0x3, false, []string{"ANSICON=80x25"}, TrueColor},
+		{"VT disabled, ConEmu", 0x3, false, []string{"ConEmuANSI=ON"}, TrueColor},
+		{"VT disabled, TERM", 0x3, false, []string{"TERM=xterm-256color"}, ANSI256},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Detector{
+				GOOS:                  "windows",
+				IsTerminal:            fakeTerminal,
+				LoadTerminfo:          noTerminfo,
+				EnableVirtualTerminal: tc.enable,
+				WindowsVersion: func() (uint32, uint32) {
+					return 10, 22631
+				},
+				OpenConsole: func(uintptr) (Console, error) {
+					return &fakeConsole{mode: tc.mode}, nil
+				},
+			}
+			if p := d.Detect(&fakeFile{fd: 42}, tc.environ); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestConsoleTextAttribute(t *testing.T) {
+	const defaults = 0x07 // white on black
+	cases := []struct {
+		name     string
+		attrs    ConsoleAttributes
+		defaults uint16
+		expected uint16
+	}{
+		{"defaults", ConsoleAttributes{}, defaults, 0x07},
+		{"other defaults", ConsoleAttributes{}, 0x1e, 0x1e},
+		{"red", ConsoleAttributes{Foreground: ansi.Red}, defaults, 0x04},
+		{"yellow on blue", ConsoleAttributes{Foreground: ansi.Yellow, Background: ansi.Blue}, defaults, 0x16},
+		{"bright cyan", ConsoleAttributes{Foreground: ansi.BrightCyan}, defaults, 0x0b},
+		{"bright magenta background", ConsoleAttributes{Background: ansi.BrightMagenta}, defaults, 0xd7},
+		{"bold", ConsoleAttributes{Bold: true}, defaults, 0x0f},
+		{"bold green", ConsoleAttributes{Foreground: ansi.Green, Bold: true}, defaults, 0x0a},
+		{"reverse", ConsoleAttributes{Reverse: true}, defaults, 0x70},
+		{"reverse red on white", ConsoleAttributes{Foreground: ansi.Red, Background: ansi.White, Reverse: true}, defaults, 0x47},
+		{"keeps other flags", ConsoleAttributes{Foreground: ansi.Red}, 0x8007, 0x8004},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if attr := consoleTextAttribute(tc.attrs, tc.defaults); attr != tc.expected {
+				t.Errorf("expected %#04x, got %#04x", tc.expected, attr)
+			}
+		})
+	}
+}
+
+// legacyConsole is a legacy Windows console recording the attributes set.
+type legacyConsole struct {
+	fakeConsole
+	recordingConsole
+}
+
+func TestDetectorNewWriterConsole(t *testing.T) {
+	cases := []struct {
+		name     string
+		major    uint32
+		build    uint32
+		mode     uint32
+		environ  []string
+		expected Profile
+		legacy   bool
+	}{
+		{"Windows 7", 6, 7601, 0x3, nil, ANSI, true},
+		{"Windows 7, NO_COLOR", 6, 7601, 0x3, []string{"NO_COLOR=1"}, ASCII, true},
+		{"Windows 7, ANSICON", 6, 7601, 0x3, []string{"ANSICON=80x25"}, ANSI, false},
+		{"Windows 11", 10, 22631, 0x7, nil, TrueColor, false},
+		{"Windows 11, VT disabled", 10, 22631, 0x3, nil, ANSI, true},
+		{"Windows 11, VT disabled, ConEmu", 10, 22631, 0x3, []string{"ConEmuANSI=ON"}, TrueColor, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &legacyConsole{fakeConsole: fakeConsole{mode: tc.mode}}
+			d := Detector{
+				GOOS:         "windows",
+				IsTerminal:   fakeTerminal,
+				LoadTerminfo: noTerminfo,
+				WindowsVersion: func() (uint32, uint32) {
+					return tc.major, tc.build
+				},
+				OpenConsole: func(uintptr) (Console, error) {
+					return c, nil
+				},
+			}
+			w := d.NewWriter(&fakeFile{fd: 42}, tc.environ)
+			if w.Profile != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, w.Profile)
+			}
+			o, _ := w.Forward.(*Output)
+			if legacy := o != nil && o.Console != nil; legacy != tc.legacy {
+				t.Fatalf("expected legacy console %v, got %v", tc.legacy, legacy)
+			}
+			if !tc.legacy {
+				return
+			}
+
+			_, _ = w.WriteString("\x1b[1;31mhi\x1b[m")
+			expected := []any{ConsoleAttributes{Bold: true}, ConsoleAttributes{}}
+			if tc.expected >= ANSI {
+				expected[0] = ConsoleAttributes{Foreground: ansi.Red, Bold: true}
+			}
+			if !reflect.DeepEqual(c.ops, expected) {
+				t.Errorf("expected %#v, got %#v", expected, c.ops)
+			}
+		})
+	}
+
+	// Detect still reports legacy consoles as NoTTY.
+	d := Detector{
+		GOOS:         "windows",
+		IsTerminal:   fakeTerminal,
+		LoadTerminfo: noTerminfo,
+		WindowsVersion: func() (uint32, uint32) {
+			return 6, 7601
+		},
+		OpenConsole: func(uintptr) (Console, error) {
+			return &legacyConsole{fakeConsole: fakeConsole{mode: 0x3}}, nil
+		},
+	}
+	if p := d.Detect(&fakeFile{fd: 42}, nil); p != NoTTY {
+		t.Errorf("expected NoTTY, got %v", p)
+	}
+
+	// And consoles without VT processing enabled still report the profile of
+	// their Windows version.
+	d.WindowsVersion = func() (uint32, uint32) {
+		return 10, 15063
+	}
+	if p := d.Detect(&fakeFile{fd: 42}, nil); p != TrueColor {
+		t.Errorf("expected TrueColor, got %v", p)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build windows
+// +build windows
+
+package colorprofile
+
+import "golang.org/x/sys/windows"
+
+var procSetConsoleTextAttribute = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetConsoleTextAttribute")
+
+// openConsole returns the Windows console the handle refers to.
+func openConsole(fd uintptr) (Console, error) {
+	c := &windowsConsole{handle: windows.Handle(fd)}
+	if _, err := c.Mode(); err != nil {
+		return nil, errNotConsole
+	}
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(c.handle, &info); err == nil {
+		c.defaults = info.Attributes
+	} else {
+		c.defaults = uint16(consoleRed | consoleGreen | consoleBlue)
+	}
+	return c, nil
+}
+
+// windowsConsole is a Windows console handle.
+type windowsConsole struct {
+	handle windows.Handle
+	// defaults is the character attribute the console started with.
+	defaults uint16
+}
+
+// Mode returns the console mode.
+func (c *windowsConsole) Mode() (uint32, error) {
+	var mode uint32
+	err := windows.GetConsoleMode(c.handle, &mode)
+	return mode, err //nolint:wrapcheck
+}
+
+// SetMode sets the console mode.
+func (c *windowsConsole) SetMode(mode uint32) error {
+	return windows.SetConsoleMode(c.handle, mode) //nolint:wrapcheck
+}
+
+// SetAttributes sets the attributes of the text written next with
+// SetConsoleTextAttribute.
+func (c *windowsConsole) SetAttributes(attrs ConsoleAttributes) error {
+	attr := consoleTextAttribute(attrs, c.defaults)
+	if r, _, err := procSetConsoleTextAttribute.Call(uintptr(c.handle), uintptr(attr)); r == 0 {
+		return err //nolint:wrapcheck
+	}
+	return nil
+}
@@ -0,0 +1,454 @@
+package colorprofile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/xo/terminfo"
+)
+
+// Detector detects color profiles. It allows customizing the detection
+// rules. The zero value is ready to use and behaves like [Detect] and [Env].
+type Detector struct {
+	// Terminals extends the built-in terminal database. Its entries are
+	// matched before the built-in ones and the editor terminals, so they can
+	// be used to override them. See [Terminal].
+	Terminals []Terminal
+
+	// IgnoreCI disables the detection of CI providers, such as GitHub
+	// Actions or GitLab CI, whose log viewers render colors even though the
+	// output isn't a terminal.
+	IgnoreCI bool
+
+	// SSH is the policy used to detect the color profile in SSH sessions.
+	// The zero value trusts TERM like in local sessions. See [SSHPolicy].
+	SSH SSHPolicy
+
+	// TTY is the terminal to query when SSH is [SSHQuery]. If nil, the
+	// controlling terminal of the process is used.
+	TTY io.ReadWriter
+
+	// Mode is the color mode requested by the user, usually with a --color
+	// command-line flag. It takes precedence over all the environment
+	// variables. See [ColorMode].
+	Mode ColorMode
+
+	// AppPrefix is the prefix of the application specific color override
+	// environment variable. For example, with "MYAPP", users can set
+	// MYAPP_COLOR to never, auto, always, or a color profile name (truecolor,
+	// ansi256, ansi, ascii, or notty) to override the detection for this
+	// application only.
+	//
+	// The overrides take precedence as follows, from highest to lowest:
+	//   - Mode, unless it's auto.
+	//   - <AppPrefix>_COLOR, unless it's auto.
+	//   - FORCE_COLOR=0..3, where 0 disables colors, and 1, 2, and 3 force
+	//     ANSI, ANSI256, and TrueColor or better.
+	//   - NO_COLOR.
+	//   - CLICOLOR_FORCE.
+	//   - CLICOLOR.
+	//
+	// FORCE_COLOR is respected even if AppPrefix is empty.
+	AppPrefix string
+
+	// Config is the user configuration overriding the detection of specific
+	// terminals. Its overrides take precedence over Terminals and the
+	// built-in rules, terminfo, and multiplexers, but not over the color
+	// overrides above. It's usually loaded with the config subpackage. If nil,
+	// no configuration is used.
+	Config *Config
+
+	// IsTerminal reports whether the file descriptor is a terminal. If nil,
+	// [term.IsTerminal] is used.
+	IsTerminal func(fd uintptr) bool
+
+	// LoadTerminfo loads the terminfo entry of a TERM name. If nil, the
+	// entry is looked up in the directories TERMINFO, HOME, and
+	// TERMINFO_DIRS of the detected environment point to, then in the system
+	// terminfo directories, and finally in a built-in database of common
+	// entries. See [TerminfoFS] to load entries from a file system.
+	LoadTerminfo func(term string) (*terminfo.Terminfo, error)
+
+	// RunCommand runs the named program and returns its standard output.
+	// It's used to probe multiplexers, e.g. with `tmux info`. If nil, the
+	// programs are run with [exec.Command].
+	RunCommand func(name string, args ...string) ([]byte, error)
+
+	// GOOS is the operating system to detect the color profile for, using
+	// the [runtime.GOOS] values. If empty, runtime.GOOS is used.
+	GOOS string
+
+	// WindowsVersion returns the Windows NT major version and build number,
+	// which tell the Windows console capabilities. If nil, the version of the
+	// running system is used, or zeros on other operating systems.
+	WindowsVersion func() (major, build uint32)
+
+	// EnableVirtualTerminal enables VT processing on Windows console outputs
+	// that don't have it yet, so they render escape sequences. Otherwise,
+	// writers created by [Detector.NewWriter] for consoles without VT
+	// processing translate styles into console attributes, and the detected
+	// profile is the one the Windows version supports.
+	EnableVirtualTerminal bool
+
+	// OpenConsole returns the Windows console of an output file descriptor,
+	// or an error if it isn't a console. If nil, the real console is used on
+	// Windows, and outputs are never consoles on other operating systems.
+	OpenConsole func(fd uintptr) (Console, error)
+
+	// PipeName returns the name of the named pipe an output file descriptor
+	// refers to. On Windows, outputs that aren't terminals are still treated
+	// as terminals if they're the pseudo-terminal pipes of Cygwin or MSYS2
+	// terminals, such as mintty. If nil, the real pipe name is used on
+	// Windows, and outputs are never pipes on other operating systems.
+	PipeName func(fd uintptr) (string, error)
+}
+
+// Explanation describes how a color profile was detected.
+type Explanation struct {
+	// Profile is the detected color profile.
+	Profile Profile
+	// Reasons are the steps that led to the profile, in order.
+	Reasons []string
+	// Limits are the colors and attributes the terminal doesn't render
+	// despite its profile. See [Output.Limits].
+	Limits Limits
+}
+
+// String returns the explanation as a multi-line string.
+func (e Explanation) String() string {
+	var b strings.Builder
+	for _, r := range e.Reasons {
+		b.WriteString(r)
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "color profile: %s", e.Profile)
+	return b.String()
+}
+
+// Explain is like [Detect] but also returns the reasons that led to the
+// detected color profile. It's useful to debug misdetections.
+func Explain(output io.Writer, env []string) Explanation {
+	return new(Detector).Explain(output, env)
+}
+
+// Detect returns the color profile based on the terminal output, and
+// environment variables. See [Detect] for the detection rules.
+func (d *Detector) Detect(output io.Writer, env []string) Profile {
+	return d.Explain(output, env).Profile
+}
+
+// Explain is like [Detector.Detect] but also returns the reasons that led to
+// the detected color profile.
+func (d *Detector) Explain(output io.Writer, env []string) Explanation {
+	s := d.newDetection(env)
+	p := s.detect(output)
+	return Explanation{Profile: p, Reasons: s.reasons, Limits: s.limits}
+}
+
+// NewWriter creates a color profile writer for the output w, like
+// [NewWriter]. On legacy Windows consoles, which don't render escape
+// sequences, the writer translates the styles into console attributes if the
+// console is a [ConsoleSink]. See [Output.Console].
+func (d *Detector) NewWriter(w io.Writer, env []string) *Writer {
+	return d.newDetection(env).writer(w)
+}
+
+// writer returns a color profile writer for the output w.
+func (s *detection) writer(w io.Writer) *Writer {
+	s.translate = true
+	p := s.detect(w)
+	return &Writer{Forward: s.output(w, p), Profile: p}
+}
+
+// output returns w wrapped in an [Output] with the legacy console and limits
+// of the detected terminal, if any, for a writer with the profile p.
+func (s *detection) output(w io.Writer, p Profile) io.Writer {
+	var console ConsoleSink
+	if s.legacy && p > NoTTY {
+		console = s.sink
+	}
+	return newOutput(w, console, s.limits)
+}
+
+// Env returns the color profile based on the terminal environment variables.
+// See [Env] for the detection rules.
+func (d *Detector) Env(env []string) Profile {
+	s := d.newDetection(env)
+	return s.colorProfile(true, true)
+}
+
+// detection is the state of a single detection.
+type detection struct {
+	*Detector
+	env environ
+	ssh bool
+	// mode is the color mode the user requested, and modeSource describes
+	// where it comes from.
+	mode       ColorMode
+	modeSource string
+	// overridden tells whether the user configuration decided the color
+	// profile.
+	overridden bool
+	// remote tells whether the detection is for a remote client, in which
+	// case session is the channel to query its terminal with.
+	remote  bool
+	session io.ReadWriter
+	// translate tells whether the detection is for a [Writer], which can
+	// translate styles for legacy Windows consoles.
+	translate bool
+	// console is the state of the Windows console output, if any. sink is
+	// the console to translate styles into attributes with when it's a
+	// legacy console and the detection is for a [Writer], and legacy tells
+	// whether it's used.
+	console *ConsoleState
+	sink    ConsoleSink
+	legacy  bool
+	// probes caches the probe results shared with other detections in the
+	// same environment, if not nil. See [Streams].
+	probes *probes
+	// known tells whether the terminal database decided the profile, in
+	// which case terminfo doesn't upgrade it.
+	known bool
+	// limits are the limits of the detected terminal.
+	limits Limits
+	// reasons are the explanations of the detection steps.
+	reasons []string
+}
+
+func (d *Detector) newDetection(env []string) *detection {
+	return d.newEnvironDetection(newEnviron(env))
+}
+
+// newEnvironDetection returns a detection in an already parsed environment.
+func (d *Detector) newEnvironDetection(env environ) *detection {
+	s := &detection{
+		Detector: d,
+		env:      env,
+	}
+	if s.ssh = sshSession(s.env); s.ssh {
+		s.explainf("running in an SSH session, using the %s policy", d.SSH)
+	}
+	s.mode, s.modeSource = s.userColorMode()
+	return s
+}
+
+// userColorMode returns the color mode the user requested using
+// [Detector.Mode], the application color variable, or FORCE_COLOR, and a
+// description of where it comes from.
+func (s *detection) userColorMode() (ColorMode, string) {
+	if s.Mode.kind() != modeAuto {
+		return s.Mode, "color mode " + s.Mode.String()
+	}
+
+	if len(s.AppPrefix) > 0 {
+		key := s.AppPrefix + "_COLOR"
+		if v, ok := s.env.lookup(key); ok {
+			m, ok := parseColorMode(v)
+			switch {
+			case !ok:
+				s.explainf("ignoring invalid %s=%s", key, v)
+			case m.kind() != modeAuto:
+				return m, key + "=" + v
+			}
+		}
+	}
+
+	if m, ok := forceColorMode(s.env); ok {
+		return m, "FORCE_COLOR=" + s.env.get("FORCE_COLOR")
+	}
+
+	return ColorAuto, ""
+}
+
+// explainf records a detection step.
+func (s *detection) explainf(format string, args ...any) {
+	s.reasons = append(s.reasons, fmt.Sprintf(format, args...))
+}
+
+// detect returns the color profile for the given output.
+func (s *detection) detect(output io.Writer) Profile {
+	out, ok := output.(term.File)
+	isatty := isTTYForced(s.env) || (ok && s.isTerminal(out.Fd()))
+	if !isatty && ok && s.goos() == "windows" {
+		isatty = s.isCygwinPty(out.Fd())
+	}
+	stdio := ok && (out.Fd() == os.Stdout.Fd() || out.Fd() == os.Stderr.Fd())
+	if ok && isatty && s.goos() == "windows" {
+		s.probeConsole(out.Fd())
+	}
+	run := s.runCommand()
+	if s.probes != nil {
+		run = s.probes.runner(run)
+	}
+	return s.detectTerminal(isatty, stdio, run)
+}
+
+// detectTerminal returns the color profile for an output that is a terminal
+// or not, and the process standard output or error or not. run probes the
+// multiplexers, if not nil.
+func (s *detection) detectTerminal(isatty, stdio bool, run commandRunner) Profile {
+	term, ok := s.env.lookup("TERM")
+	isDumb := !ok || term == dumbTerm
+	envp := s.colorProfile(isatty, stdio)
+	noColor := envNoColor(s.env) && s.mode.kind() == modeAuto
+	if !isatty || isDumb || noColor || s.mode.final() || s.overridden {
+		// Not a terminal, NO_COLOR is set, or the user asked for a specific
+		// profile.
+		return envp
+	}
+
+	muxes := multiplexers(s.env, run)
+	if envp == TrueColor && len(muxes) == 0 {
+		// We already know we have TrueColor.
+		return envp
+	}
+
+	// Color profile is the maximum of env and terminfo, capped by the
+	// multiplexers we're running under.
+	// Known terminals know their color support better than their terminfo
+	// entries.
+	p := envp
+	if caps, source, ok := s.terminfo(term); ok && !s.known {
+		if tip := caps.Profile(); tip > p {
+			entry := "terminfo entry for TERM=" + term
+			if len(source) > 0 {
+				entry += " in " + source
+			}
+			s.explainf("%s has %s, supporting %s", entry, caps, tip)
+			p = tip
+		}
+	}
+
+	if s.ssh && s.SSH == SSHQuery && p < TrueColor && s.cachedQueryTrueColor() {
+		p = TrueColor
+	}
+
+	for _, m := range muxes {
+		name := m.Name
+		if len(m.Version) > 0 {
+			name += " " + m.Version
+		}
+		s.explainf("running under %s, which passes %s through", name, m.Profile)
+	}
+
+	return multiplexersProfile(p, term, muxes)
+}
+
+// isTerminal reports whether the file descriptor is a terminal.
+func (d *Detector) isTerminal(fd uintptr) bool {
+	if d.IsTerminal != nil {
+		return d.IsTerminal(fd)
+	}
+	return term.IsTerminal(fd)
+}
+
+// terminfo returns the color capabilities of the terminfo entry of term,
+// and where it was found.
+func (s *detection) terminfo(term string) (TerminfoCaps, string, bool) {
+	if s.probes != nil {
+		return s.probes.terminfo(term, s.loadTerminfo)
+	}
+	return s.loadTerminfo(term)
+}
+
+// loadTerminfo loads the color capabilities of the terminfo entry of term.
+func (s *detection) loadTerminfo(term string) (TerminfoCaps, string, bool) {
+	if len(term) == 0 || term == dumbTerm {
+		return TerminfoCaps{}, "", false
+	}
+	if !validTermName(term) {
+		s.explainf("ignoring the terminfo entry of the invalid TERM=%q", term)
+		return TerminfoCaps{}, "", false
+	}
+
+	if s.LoadTerminfo != nil {
+		ti, err := s.LoadTerminfo(term)
+		if err != nil {
+			return TerminfoCaps{}, "", false
+		}
+		return ParseTerminfo(ti), "", true
+	}
+
+	// The environment of remote clients doesn't tell where the local
+	// terminfo entries are.
+	dirs := systemTerminfoDirs
+	if !s.remote {
+		dirs = terminfoDirs(s.env)
+	}
+	for _, dir := range dirs {
+		if ti, err := terminfo.Open(dir, term); err == nil {
+			return ParseTerminfo(ti), dir, true
+		}
+	}
+
+	if caps, ok := builtinTerminfo[term]; ok {
+		return caps, "the built-in database", true
+	}
+
+	return TerminfoCaps{}, "", false
+}
+
+// runCommand returns the command runner used to probe multiplexers.
+func (d *Detector) runCommand() commandRunner {
+	if d.RunCommand != nil {
+		return d.RunCommand
+	}
+	return execCommand
+}
+
+// goos returns the operating system to detect the color profile for.
+func (d *Detector) goos() string {
+	if len(d.GOOS) > 0 {
+		return d.GOOS
+	}
+	return runtime.GOOS
+}
+
+// probeConsole probes the Windows console of the output file descriptor, and
+// enables VT processing if requested.
+func (s *detection) probeConsole(fd uintptr) {
+	open := openConsole
+	if s.OpenConsole != nil {
+		open = s.OpenConsole
+	}
+	c, err := open(fd)
+	if err != nil {
+		return
+	}
+
+	major, build := s.windowsVersion()
+	state, err := ProbeConsole(c, s.EnableVirtualTerminal, major, build)
+	if err != nil {
+		return
+	}
+	switch {
+	case state.Enabled:
+		s.explainf("enabled VT processing on the Windows console")
+	case state.VT:
+		s.explainf("Windows console has VT processing enabled")
+	default:
+		s.explainf("Windows console doesn't have VT processing enabled")
+	}
+	s.console = &state
+	if sink, ok := c.(ConsoleSink); ok && s.translate {
+		s.sink = sink
+	}
+}
+
+// windowsVersion returns the Windows NT major version and build number.
+func (d *Detector) windowsVersion() (major, build uint32) {
+	if d.WindowsVersion != nil {
+		return d.WindowsVersion()
+	}
+	return windowsVersion()
+}
+
+// windowsColorProfile returns the color profile of the Windows console.
+func (d *Detector) windowsColorProfile(env environ) Profile {
+	major, build := d.windowsVersion()
+	return windowsProfile(env, major, build)
+}
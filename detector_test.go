@@ -0,0 +1,154 @@
+package colorprofile
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/xo/terminfo"
+)
+
+// fakeFile is a file with a made up file descriptor.
+type fakeFile struct {
+	bytes.Buffer
+	fd uintptr
+}
+
+func (f *fakeFile) Fd() uintptr  { return f.fd }
+func (f *fakeFile) Close() error { return nil }
+
+// fakeTerminal reports whether fd is the made up terminal file descriptor.
+func fakeTerminal(fd uintptr) bool { return fd == 42 }
+
+// noTerminfo is a terminfo loader that doesn't find any entry.
+func noTerminfo(string) (*terminfo.Terminfo, error) {
+	return nil, terminfo.ErrFileNotFound
+}
+
+func TestDetectorInjection(t *testing.T) {
+	direct, err := TerminfoFS(os.DirFS("testdata/terminfo"))("xterm-direct")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		detector Detector
+		fd       uintptr
+		environ  []string
+		expected Profile
+	}{
+		{
+			name:     "not a terminal",
+			detector: Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo},
+			fd:       7,
+			environ:  []string{"TERM=xterm-256color"},
+			expected: NoTTY,
+		},
+		{
+			name:     "terminal",
+			detector: Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo},
+			fd:       42,
+			environ:  []string{"TERM=xterm-256color"},
+			expected: ANSI256,
+		},
+		{
+			name: "terminfo",
+			detector: Detector{
+				IsTerminal: fakeTerminal,
+				LoadTerminfo: func(string) (*terminfo.Terminfo, error) {
+					return direct, nil
+				},
+			},
+			fd:       42,
+			environ:  []string{"TERM=myterm"},
+			expected: TrueColor,
+		},
+		{
+			name: "tmux without Tc",
+			detector: Detector{
+				IsTerminal:   fakeTerminal,
+				LoadTerminfo: noTerminfo,
+				RunCommand:   fakeRunner(map[string]string{"tmux": " 196: Tc: [missing]\n"}),
+			},
+			fd:       42,
+			environ:  []string{"TERM=tmux-256color", "TMUX=/tmp/tmux-1000/default,1,0", "COLORTERM=truecolor"},
+			expected: ANSI256,
+		},
+		{
+			name: "tmux with Tc",
+			detector: Detector{
+				IsTerminal:   fakeTerminal,
+				LoadTerminfo: noTerminfo,
+				RunCommand:   fakeRunner(map[string]string{"tmux": tmuxInfoTc}),
+			},
+			fd:       42,
+			environ:  []string{"TERM=tmux-256color", "TMUX=/tmp/tmux-1000/default,1,0", "COLORTERM=truecolor"},
+			expected: TrueColor,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.detector.Detect(&fakeFile{fd: tc.fd}, tc.environ)
+			if p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestDetectorEmptyTerm(t *testing.T) {
+	environ := []string{"TTY_FORCE=1", "TERM="}
+	if p := (&Detector{GOOS: "linux"}).Detect(&fakeFile{fd: 42}, environ); p != NoTTY {
+		t.Errorf("expected NoTTY, got %v", p)
+	}
+
+	d := Detector{
+		GOOS: "linux",
+		LoadTerminfo: func(term string) (*terminfo.Terminfo, error) {
+			t.Errorf("unexpected terminfo lookup of %q", term)
+			return nil, terminfo.ErrFileNotFound
+		},
+	}
+	for _, environ := range [][]string{environ, {"TTY_FORCE=1", "TERM=dumb", "CLICOLOR_FORCE=1"}} {
+		d.Detect(&fakeFile{fd: 42}, environ)
+	}
+}
+
+func TestDetectorWindows(t *testing.T) {
+	cases := []struct {
+		name         string
+		major, build uint32
+		environ      []string
+		expected     Profile
+	}{
+		{"Windows 7", 6, 7601, nil, NoTTY},
+		{"Windows 7, ANSICON", 6, 7601, []string{"ANSICON=80x25"}, ANSI},
+		{"Windows 7, ANSICON 1.81", 6, 7601, []string{"ANSICON=80x25", "ANSICON_VER=181"}, ANSI256},
+		{"Windows 7, ConEmu", 6, 7601, []string{"ConEmuANSI=ON"}, TrueColor},
+		{"Windows 10 1511", 10, 10586, nil, ANSI256},
+		{"Windows 10 1703", 10, 15063, nil, TrueColor},
+		{"Windows 7, Windows Terminal", 6, 7601, []string{"WT_SESSION=1"}, TrueColor},
+		{"Windows 7, TERM", 6, 7601, []string{"TERM=xterm-256color"}, ANSI256},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Detector{
+				GOOS: "windows",
+				WindowsVersion: func() (uint32, uint32) {
+					return tc.major, tc.build
+				},
+			}
+			if p := d.Env(tc.environ); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+
+	d := Detector{GOOS: "linux"}
+	if p := d.Env(nil); p != NoTTY {
+		t.Errorf("expected NoTTY on linux, got %v", p)
+	}
+}
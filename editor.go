@@ -0,0 +1,79 @@
+package colorprofile
+
+import (
+	"strconv"
+	"strings"
+)
+
+// editor describes a terminal embedded in an editor or an IDE. Their
+// environment is inherited from the terminal the editor runs in, so TERM,
+// TERM_PROGRAM, and COLORTERM can't be trusted.
+type editor struct {
+	// name is the name of the editor terminal.
+	name string
+	// profile is the color profile the editor terminal supports.
+	profile Profile
+	// dumb tells whether the editor renders colors even though it sets
+	// TERM=dumb.
+	dumb bool
+}
+
+// editorTerminal returns the editor terminal the environment runs in, if
+// any. Editors come before IDEs since editors run in IDE terminals, not the
+// other way around.
+func editorTerminal(env environ) (editor, bool) {
+	if v, ok := env.lookup("INSIDE_EMACS"); ok {
+		if e, ok := emacsTerminal(v); ok {
+			return e, true
+		}
+	}
+
+	if v := env.get("VIM_TERMINAL"); len(v) > 0 {
+		// Vim only renders true colors with 'termguicolors', which the
+		// environment doesn't tell.
+		return editor{name: "Vim terminal " + v, profile: ANSI256}, true
+	}
+
+	if len(env.get("NVIM")) > 0 || len(env.get("NVIM_LISTEN_ADDRESS")) > 0 {
+		return editor{name: "Neovim terminal", profile: TrueColor}, true
+	}
+
+	if env.get("TERMINAL_EMULATOR") == "JetBrains-JediTerm" {
+		return editor{name: "JetBrains terminal", profile: TrueColor}, true
+	}
+
+	return editor{}, false
+}
+
+// emacsTerminal returns the Emacs terminal of an INSIDE_EMACS value, e.g.
+// "29.1,comint" for M-x shell, "29.1,eshell", "29.1,compile", "29.1,term:0.96"
+// for M-x term, or "vterm".
+func emacsTerminal(v string) (editor, bool) {
+	version, kind, _ := strings.Cut(v, ",")
+	if version == "vterm" || strings.HasPrefix(kind, "vterm") {
+		return editor{name: "Emacs vterm", profile: TrueColor}, true
+	}
+
+	// ansi-color supports 256 and true colors since Emacs 28.
+	major, _, _ := strings.Cut(version, ".")
+	colors := ANSI
+	if n, err := strconv.Atoi(major); err == nil && n >= 28 { //nolint:mnd
+		colors = TrueColor
+	}
+
+	switch {
+	case kind == "comint":
+		return editor{name: "Emacs " + version + " shell", profile: colors, dumb: true}, true
+	case kind == "eshell":
+		return editor{name: "Emacs " + version + " eshell", profile: colors, dumb: true}, true
+	case kind == "compile":
+		// compilation-mode shows escape sequences as is, unless
+		// ansi-color-compilation-filter is enabled.
+		return editor{name: "Emacs " + version + " compilation buffer", profile: NoTTY}, true
+	case strings.HasPrefix(kind, "term:"):
+		// M-x term and ansi-term use TERM=eterm-color.
+		return editor{name: "Emacs " + version + " term", profile: ANSI}, true
+	}
+
+	return editor{}, false
+}
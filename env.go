@@ -1,16 +1,9 @@
 package colorprofile
 
 import (
-	"bytes"
-	"context"
 	"io"
-	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
-
-	"github.com/charmbracelet/x/term"
-	"github.com/xo/terminfo"
 )
 
 const dumbTerm = "dumb"
@@ -24,33 +17,51 @@ const dumbTerm = "dumb"
 //   - If COLORTERM=truecolor, and the profile is not NoTTY, it gest upgraded to TrueColor.
 //   - Using any 256 color terminal (e.g. TERM=xterm-256color) will set the profile to ANSI256.
 //   - Using any color terminal (e.g. TERM=xterm-color) will set the profile to ANSI.
+//   - Known terminals are looked up in the built-in terminal database by
+//     their TERM name and family, and TERM_PROGRAM and TERM_PROGRAM_VERSION.
+//     The profile of a known terminal isn't upgraded by its TERM name or
+//     terminfo entry. See [Terminal].
 //   - Using CLICOLOR=1 without TERM defined should be treated as ANSI if the
 //     output is a terminal.
 //   - NO_COLOR takes precedence over CLICOLOR/CLICOLOR_FORCE, and will disable
 //     colors but not text decoration, i.e. bold, italic, faint, etc.
+//   - The terminfo entry of TERM can upgrade the profile based on its colors,
+//     Tc, RGB, and setrgbf capabilities. See [TerminfoCaps]. The entry is
+//     searched using TERMINFO, TERMINFO_DIRS, and HOME from env, falling back
+//     to a built-in database of common entries.
+//   - Running under tmux, GNU Screen, or Zellij caps the profile to what each
+//     of the multiplexers passes through. See [Multiplexers].
+//   - FORCE_COLOR=0..3 takes precedence over NO_COLOR, and disables colors
+//     or forces ANSI, ANSI256, and TrueColor, following the Node.js
+//     convention. See [Detector.AppPrefix] for application specific overrides.
+//   - Running on a CI provider whose log viewer renders colors, e.g.
+//     GITHUB_ACTIONS=true, uses the profile of the log viewer when the output
+//     is the standard output or error, even if it isn't a terminal. See
+//     [Detector.IgnoreCI] to opt out.
+//   - On Windows, consoles support the colors of their Windows version,
+//     which may require enabling VT processing first. See
+//     [Detector.EnableVirtualTerminal] to enable it. A [Writer] from
+//     [NewWriter] translates styles into console attributes when VT
+//     processing isn't enabled, unless ConEmu or ANSICON translate the
+//     escape sequences.
+//   - The Linux console, fbterm, and kmscon support ANSI, ANSI, and ANSI256
+//     colors respectively, regardless of their terminfo entries, and have
+//     [Limits] a [Writer] from [NewWriter] degrades styles for.
+//   - Terminals embedded in editors and IDEs, such as Emacs shells and vterm
+//     (INSIDE_EMACS), Vim (VIM_TERMINAL), Neovim (NVIM), and JetBrains IDEs
+//     (TERMINAL_EMULATOR), are detected before the inherited TERM_PROGRAM
+//     and COLORTERM. Emacs shells render colors despite TERM=dumb.
+//   - On Windows, the pseudo-terminal pipes of Cygwin and MSYS2 terminals,
+//     such as mintty in Git Bash, are treated as terminals, as are other
+//     named pipes if TERM_PROGRAM=mintty or MSYSTEM is set, e.g. behind
+//     winpty, unless they're pipelines.
+//
+// Use a [Detector] with a [Config] to let users override the detection of
+// their terminals with a configuration file.
 //
 // See https://no-color.org/ and https://bixense.com/clicolors/ for more information.
 func Detect(output io.Writer, env []string) Profile {
-	out, ok := output.(term.File)
-	environ := newEnviron(env)
-	isatty := isTTYForced(environ) || (ok && term.IsTerminal(out.Fd()))
-	term, ok := environ.lookup("TERM")
-	isDumb := !ok || term == dumbTerm
-	envp := colorProfile(isatty, environ)
-	if envp == TrueColor || envNoColor(environ) {
-		// We already know we have TrueColor, or NO_COLOR is set.
-		return envp
-	}
-
-	if isatty && !isDumb {
-		tip := Terminfo(term)
-		tmuxp := tmux(environ)
-
-		// Color profile is the maximum of env, terminfo, and tmux.
-		return max(envp, max(tip, tmuxp))
-	}
-
-	return envp
+	return new(Detector).Detect(output, env)
 }
 
 // Env returns the color profile based on the terminal environment variables.
@@ -61,30 +72,68 @@ func Detect(output io.Writer, env []string) Profile {
 //   - If COLORTERM=truecolor, and the profile is not NoTTY, it gest upgraded to TrueColor.
 //   - Using any 256 color terminal (e.g. TERM=xterm-256color) will set the profile to ANSI256.
 //   - Using any color terminal (e.g. TERM=xterm-color) will set the profile to ANSI.
+//   - Known terminals are looked up in the built-in terminal database by
+//     their TERM name and family, and TERM_PROGRAM and TERM_PROGRAM_VERSION.
+//     The profile of a known terminal isn't upgraded by its TERM name or
+//     terminfo entry. See [Terminal].
 //   - Using CLICOLOR=1 without TERM defined should be treated as ANSI if the
 //     output is a terminal.
 //   - NO_COLOR takes precedence over CLICOLOR/CLICOLOR_FORCE, and will disable
 //     colors but not text decoration, i.e. bold, italic, faint, etc.
+//   - FORCE_COLOR=0..3 takes precedence over NO_COLOR, and disables colors
+//     or forces ANSI, ANSI256, and TrueColor, following the Node.js
+//     convention. See [Detector.AppPrefix] for application specific overrides.
+//   - Running on a CI provider whose log viewer renders colors, e.g.
+//     GITHUB_ACTIONS=true, uses the profile of the log viewer. See
+//     [Detector.IgnoreCI] to opt out.
 //
 // See https://no-color.org/ and https://bixense.com/clicolors/ for more information.
 func Env(env []string) (p Profile) {
-	return colorProfile(true, newEnviron(env))
+	return new(Detector).Env(env)
 }
 
-func colorProfile(isatty bool, env environ) (p Profile) {
+// colorProfile returns the color profile based on the environment. isatty
+// tells whether the output is a terminal, and stdio whether it's the process
+// standard output or error, which CI log viewers render.
+func (s *detection) colorProfile(isatty, stdio bool) (p Profile) {
+	env := s.env
 	term, ok := env.lookup("TERM")
-	isDumb := (!ok && runtime.GOOS != "windows") || term == dumbTerm
-	envp := envColorProfile(env)
-	if !isatty || isDumb {
+	isDumb := (!ok && (s.remote || s.goos() != "windows")) || term == dumbTerm
+	if e, ok := editorTerminal(env); ok && e.dumb && term == dumbTerm {
+		// Emacs shells render colors despite TERM=dumb.
+		isDumb = false
+	}
+	envp := s.envColorProfile()
+	switch {
+	case !isatty:
 		// Check if the output is a terminal.
+		s.explainf("output is not a terminal")
+		p = NoTTY
+	case isDumb:
 		// Treat dumb terminals as NoTTY
+		s.explainf("TERM is unset or dumb")
 		p = NoTTY
-	} else {
+	default:
 		p = envp
 	}
 
+	if ci, ok := ciColorProfile(env); ok && stdio && !s.IgnoreCI && p < ci.profile {
+		// CI log viewers render colors even though the output isn't a
+		// terminal.
+		s.explainf("running on %s, whose log viewer supports %s", ci.name, ci.profile)
+		p = ci.profile
+		isatty = true
+	}
+
+	if s.mode.kind() != modeAuto {
+		p = s.mode.apply(p, envp)
+		s.explainf("%s, using %s", s.modeSource, p)
+		return p
+	}
+
 	if envNoColor(env) && isatty {
 		if p > ASCII {
+			s.explainf("NO_COLOR is set, disabling colors")
 			p = ASCII
 		}
 		return //nolint:nakedret
@@ -97,12 +146,14 @@ func colorProfile(isatty bool, env environ) (p Profile) {
 		if envp > p {
 			p = envp
 		}
+		s.explainf("CLICOLOR_FORCE is set, forcing %s", p)
 
 		return //nolint:nakedret
 	}
 
 	if cliColor(env) {
 		if isatty && !isDumb && p < ANSI {
+			s.explainf("CLICOLOR is set, enabling colors")
 			p = ANSI
 		}
 	}
@@ -139,93 +190,115 @@ func colorTerm(env environ) bool {
 }
 
 // envColorProfile returns infers the color profile from the environment.
-func envColorProfile(env environ) (p Profile) {
+func (s *detection) envColorProfile() (p Profile) {
+	env := s.env
 	term, ok := env.lookup("TERM")
 	if !ok || len(term) == 0 || term == dumbTerm {
 		p = NoTTY
-		if runtime.GOOS == "windows" {
+		if s.goos() == "windows" && !s.remote {
 			// Use Windows API to detect color profile. Windows Terminal and
 			// cmd.exe don't define $TERM.
-			if wcp, ok := windowsColorProfile(env); ok {
-				p = wcp
+			wcp := s.windowsColorProfile(env)
+			if s.console != nil {
+				wcp, s.legacy = consoleProfile(env, *s.console, wcp, s.sink != nil)
+				if s.legacy {
+					s.explainf("translating styles into legacy console attributes")
+				}
 			}
+			s.explainf("Windows console supports %s", wcp)
+			p = wcp
 		}
 	} else {
 		p = ANSI
 	}
 
-	switch {
-	case strings.Contains(term, "alacritty"),
-		strings.Contains(term, "contour"),
-		strings.Contains(term, "foot"),
-		strings.Contains(term, "ghostty"),
-		strings.Contains(term, "kitty"),
-		strings.Contains(term, "rio"),
-		strings.Contains(term, "st"),
-		strings.Contains(term, "wezterm"):
-		return TrueColor
-	case strings.HasPrefix(term, "tmux"), strings.HasPrefix(term, "screen"):
-		if p < ANSI256 {
-			p = ANSI256
+	lookupEnv := env
+	if s.ssh && s.SSH == SSHLCTerminal {
+		if lcEnv, ok := lcTerminalEnv(env); ok {
+			s.explainf("using LC_TERMINAL=%s forwarded over SSH", env.get("LC_TERMINAL"))
+			lookupEnv = lcEnv
 		}
-	case strings.HasPrefix(term, "xterm"):
-		if p < ANSI {
-			p = ANSI
+	}
+
+	o, overridden := s.Config.lookup(lookupEnv)
+	if overridden && o.Profile != Unknown {
+		s.explainf("config file overrides %s with %s", o, o.Profile)
+		s.overridden = true
+		return o.Profile
+	}
+
+	// User terminals come first, so they can correct misdetected editor
+	// terminals, then the editor terminals, whose environment is inherited,
+	// and the built-in terminals.
+	t, known := lookupTerminal(s.Terminals, lookupEnv)
+	if !known {
+		if e, ok := editorTerminal(env); ok {
+			s.explainf("running in %s, which supports %s", e.name, e.profile)
+			return e.profile
+		}
+		t, known = lookupTerminal(terminals, lookupEnv)
+	}
+	if overridden && o.IgnoreColorTerm {
+		s.explainf("config file ignores COLORTERM for %s", o)
+		t.IgnoreColorTerm = true
+	}
+	if known {
+		// The terminal database knows better than the TERM name and the
+		// terminfo entry, and users can override it either way.
+		s.explainf("%s supports %s", t, t.Profile)
+		if t.Limits != (Limits{}) {
+			s.explainf("%s has %s", t, t.Limits)
+			s.limits = t.Limits
 		}
+		s.known = true
+		p = t.Profile
 	}
 
 	if len(env["WT_SESSION"]) > 0 {
 		// Windows Terminal supports TrueColor
+		s.explainf("WT_SESSION is set, Windows Terminal supports TrueColor")
 		return TrueColor
 	}
 
 	if isCloudShell, _ := strconv.ParseBool(env.get("GOOGLE_CLOUD_SHELL")); isCloudShell {
+		s.explainf("GOOGLE_CLOUD_SHELL is set, Cloud Shell supports TrueColor")
 		return TrueColor
 	}
 
-	// GNU Screen doesn't support TrueColor
-	// Tmux doesn't support $COLORTERM
-	if colorTerm(env) && !strings.HasPrefix(term, "screen") && !strings.HasPrefix(term, "tmux") {
-		return TrueColor
+	// Some terminals, such as GNU Screen and tmux, don't support $COLORTERM.
+	if colorTerm(env) {
+		if !t.IgnoreColorTerm {
+			s.explainf("COLORTERM=%s, upgrading to TrueColor", env.get("COLORTERM"))
+			return TrueColor
+		}
+		s.explainf("ignoring COLORTERM=%s for TERM=%s", env.get("COLORTERM"), term)
+	} else if s.ssh && p < TrueColor {
+		s.explainf("COLORTERM is usually not forwarded over SSH")
+	}
+
+	if known {
+		return p
 	}
 
 	if strings.HasSuffix(term, "256color") && p < ANSI256 {
+		s.explainf("TERM=%s supports ANSI256", term)
 		p = ANSI256
 	}
 
 	// Direct color terminals support true colors.
-	if strings.HasSuffix(term, "direct") {
+	if directTerm(term) {
+		s.explainf("TERM=%s is a direct color terminal", term)
 		return TrueColor
 	}
 
 	return //nolint:nakedret
 }
 
-// Terminfo returns the color profile based on the terminal's terminfo
-// database. This relies on the Tc and RGB capabilities to determine if the
-// terminal supports TrueColor.
-// If term is empty or "dumb", it returns NoTTY.
-func Terminfo(term string) (p Profile) {
-	if len(term) == 0 || term == "dumb" {
-		return NoTTY
-	}
-
-	p = ANSI
-	ti, err := terminfo.Load(term)
-	if err != nil {
-		return
-	}
-
-	extbools := ti.ExtBoolCapsShort()
-	if _, ok := extbools["Tc"]; ok {
-		return TrueColor
-	}
-
-	if _, ok := extbools["RGB"]; ok {
-		return TrueColor
-	}
-
-	return
+// directTerm reports whether the TERM name is a direct color variant, e.g.
+// xterm-direct, or xterm-direct16 whose first 16 colors are indexed.
+func directTerm(term string) bool {
+	i := strings.LastIndex(term, "-direct")
+	return i >= 0 && strings.Trim(term[i+len("-direct"):], "0123456789") == ""
 }
 
 // Tmux returns the color profile based on `tmux info` output. Tmux supports
@@ -236,29 +309,14 @@ func Tmux(env []string) Profile {
 }
 
 // tmux returns the color profile based on the tmux environment variables.
-func tmux(env environ) (p Profile) {
+func tmux(env environ) Profile {
 	if tmux, ok := env.lookup("TMUX"); !ok || len(tmux) == 0 {
 		// Not in tmux
 		return NoTTY
 	}
 
-	// Check if tmux has either Tc or RGB capabilities. Otherwise, return
-	// ANSI256.
-	p = ANSI256
-	cmd := exec.CommandContext(context.Background(), "tmux", "info")
-	out, err := cmd.Output()
-	if err != nil {
-		return
-	}
-
-	for line := range bytes.SplitSeq(out, []byte("\n")) {
-		if (bytes.Contains(line, []byte("Tc")) || bytes.Contains(line, []byte("RGB"))) &&
-			bytes.Contains(line, []byte("true")) {
-			return TrueColor
-		}
-	}
-
-	return
+	info, _ := execCommand("tmux", "info")
+	return tmuxProfile(tmuxVersion(env), info)
 }
 
 // environ is a map of environment variables.
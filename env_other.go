@@ -3,6 +3,8 @@
 
 package colorprofile
 
-func windowsColorProfile(map[string]string) (Profile, bool) {
-	return 0, false
+// windowsVersion returns the Windows NT major version and build number. It
+// returns zeros on other operating systems.
+func windowsVersion() (major, build uint32) {
+	return 0, 0
 }
@@ -15,7 +15,7 @@ var cases = []struct {
 		environ: []string{},
 		expected: func() Profile {
 			if runtime.GOOS == "windows" {
-				p, _ := windowsColorProfile(map[string]string{})
+				p := new(Detector).windowsColorProfile(environ{})
 				return p
 			} else {
 				return NoTTY
@@ -207,6 +207,30 @@ var cases = []struct {
 		},
 		expected: ANSI256,
 	},
+	{
+		name: "linux console colorterm",
+		environ: []string{
+			"TERM=linux",
+			"COLORTERM=truecolor",
+		},
+		expected: ANSI,
+	},
+	{
+		name: "fbterm colorterm",
+		environ: []string{
+			"TERM=fbterm",
+			"COLORTERM=truecolor",
+		},
+		expected: ANSI,
+	},
+	{
+		name: "kmscon colorterm",
+		environ: []string{
+			"TERM=kmscon",
+			"COLORTERM=truecolor",
+		},
+		expected: ANSI256,
+	},
 	{
 		name: "ignore COLORTERM when no TERM is defined",
 		environ: []string{
@@ -214,7 +238,7 @@ var cases = []struct {
 		},
 		expected: func() Profile {
 			if runtime.GOOS == "windows" {
-				p, _ := windowsColorProfile(map[string]string{})
+				p := new(Detector).windowsColorProfile(environ{})
 				return p
 			} else {
 				return NoTTY
@@ -228,6 +252,258 @@ var cases = []struct {
 		},
 		expected: TrueColor,
 	},
+	{
+		name: "Apple Terminal",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=Apple_Terminal",
+			"TERM_PROGRAM_VERSION=455.1",
+		},
+		expected: ANSI256,
+	},
+	{
+		name: "Apple Terminal, xterm",
+		environ: []string{
+			"TERM=xterm",
+			"TERM_PROGRAM=Apple_Terminal",
+			"TERM_PROGRAM_VERSION=455.1",
+		},
+		expected: ANSI256,
+	},
+	{
+		name: "Apple Terminal with true colors",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=Apple_Terminal",
+			"TERM_PROGRAM_VERSION=465",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "Apple Terminal, COLORTERM=truecolor",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=Apple_Terminal",
+			"TERM_PROGRAM_VERSION=455.1",
+			"COLORTERM=truecolor",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "iTerm2",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=iTerm.app",
+			"TERM_PROGRAM_VERSION=3.5.4",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "iTerm2 2.x",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=iTerm.app",
+			"TERM_PROGRAM_VERSION=2.1.4",
+		},
+		expected: ANSI256,
+	},
+	{
+		name: "iTerm2 without version",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=iTerm.app",
+		},
+		expected: ANSI256,
+	},
+	{
+		name: "VS Code",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=vscode",
+			"TERM_PROGRAM_VERSION=1.95.3",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "Hyper",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=Hyper",
+			"TERM_PROGRAM_VERSION=3.4.1",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "WezTerm",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=WezTerm",
+			"TERM_PROGRAM_VERSION=20240203-110809-5046fc22",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "Ghostty",
+		environ: []string{
+			"TERM=xterm-ghostty",
+			"TERM_PROGRAM=ghostty",
+			"TERM_PROGRAM_VERSION=1.1.3",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "Ghostty, xterm-256color",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=ghostty",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "alacritty launched from Apple Terminal",
+		environ: []string{
+			"TERM=alacritty",
+			"TERM_PROGRAM=Apple_Terminal",
+			"TERM_PROGRAM_VERSION=455.1",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "screen in iTerm2",
+		environ: []string{
+			"TERM=screen",
+			"TERM_PROGRAM=iTerm.app",
+			"TERM_PROGRAM_VERSION=3.5.4",
+		},
+		expected: ANSI256,
+	},
+	{
+		name: "emacs shell",
+		environ: []string{
+			"INSIDE_EMACS=29.4,comint",
+			"TERM=dumb",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "emacs 27 shell",
+		environ: []string{
+			"INSIDE_EMACS=27.1,comint",
+			"TERM=dumb",
+		},
+		expected: ANSI,
+	},
+	{
+		name: "emacs eshell",
+		environ: []string{
+			"INSIDE_EMACS=30.1,eshell",
+			"TERM=dumb",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "emacs compilation buffer",
+		environ: []string{
+			"INSIDE_EMACS=29.4,compile",
+			"TERM=dumb",
+			"COLORTERM=truecolor",
+		},
+		expected: NoTTY,
+	},
+	{
+		name: "emacs term",
+		environ: []string{
+			"INSIDE_EMACS=29.4,term:0.96",
+			"TERM=eterm-color",
+			"COLORTERM=truecolor",
+		},
+		expected: ANSI,
+	},
+	{
+		name: "emacs vterm",
+		environ: []string{
+			"INSIDE_EMACS=vterm",
+			"TERM=xterm-256color",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "emacs vterm with version",
+		environ: []string{
+			"INSIDE_EMACS=29.4,vterm",
+			"TERM=xterm-256color",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "dumb term with unknown INSIDE_EMACS",
+		environ: []string{
+			"INSIDE_EMACS=t",
+			"TERM=dumb",
+		},
+		expected: NoTTY,
+	},
+	{
+		name: "vim terminal ignores inherited COLORTERM",
+		environ: []string{
+			"VIM_TERMINAL=901",
+			"TERM=xterm-256color",
+			"COLORTERM=truecolor",
+			"TERM_PROGRAM=iTerm.app",
+			"TERM_PROGRAM_VERSION=3.5.4",
+		},
+		expected: ANSI256,
+	},
+	{
+		name: "neovim terminal",
+		environ: []string{
+			"NVIM=/run/user/1000/nvim.1234.0",
+			"TERM=xterm-256color",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "neovim terminal with NVIM_LISTEN_ADDRESS",
+		environ: []string{
+			"NVIM_LISTEN_ADDRESS=/tmp/nvimXXXX/0",
+			"TERM=xterm-256color",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "jetbrains terminal",
+		environ: []string{
+			"TERMINAL_EMULATOR=JetBrains-JediTerm",
+			"TERM=xterm-256color",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "vim terminal in vscode",
+		environ: []string{
+			"VIM_TERMINAL=901",
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=vscode",
+		},
+		expected: ANSI256,
+	},
+	{
+		name: "vscode",
+		environ: []string{
+			"TERM=xterm-256color",
+			"TERM_PROGRAM=vscode",
+		},
+		expected: TrueColor,
+	},
+	{
+		name: "emacs shell with NO_COLOR",
+		environ: []string{
+			"INSIDE_EMACS=29.4,comint",
+			"TERM=dumb",
+			"NO_COLOR=1",
+		},
+		expected: ASCII,
+	},
 }
 
 func TestEnvColorProfile(t *testing.T) {
@@ -0,0 +1,107 @@
+package colorprofile
+
+import "strings"
+
+// Attrs is a set of text attributes.
+type Attrs uint16
+
+// Text attributes.
+const (
+	AttrBold Attrs = 1 << iota
+	AttrFaint
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrConceal
+	AttrStrikethrough
+	AttrOverline
+	AttrUnderlineColor
+)
+
+// attrNames are the names of the text attributes, in bit order.
+var attrNames = []string{
+	"bold",
+	"faint",
+	"italic",
+	"underline",
+	"blink",
+	"reverse",
+	"conceal",
+	"strikethrough",
+	"overline",
+	"underline color",
+}
+
+// String returns the names of the attributes in the set.
+func (a Attrs) String() string {
+	var names []string
+	for i, name := range attrNames {
+		if a&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// sgrAttrs are the attributes the SGR parameters enabling them set.
+var sgrAttrs = map[int]Attrs{
+	1:  AttrBold,
+	2:  AttrFaint,
+	3:  AttrItalic,
+	4:  AttrUnderline,
+	5:  AttrBlink,
+	6:  AttrBlink,
+	7:  AttrReverse,
+	8:  AttrConceal,
+	9:  AttrStrikethrough,
+	21: AttrUnderline, // double underline
+	53: AttrOverline,
+	58: AttrUnderlineColor,
+}
+
+// Limits describes what a terminal doesn't render even though its color
+// profile suggests it does, like the Linux console, which renders the ANSI
+// colors but only 8 background colors and no italics. A [Writer] degrades
+// the styles it writes accordingly. The zero value has no limits.
+type Limits struct {
+	// Unsupported are the attributes the terminal doesn't render. The
+	// Writer strips them.
+	Unsupported Attrs
+	// NoBrightBackground tells whether the terminal renders bright
+	// background colors as their normal counterparts, or not at all. The
+	// Writer uses the normal colors instead.
+	NoBrightBackground bool
+}
+
+// String describes the limits.
+func (l Limits) String() string {
+	var parts []string
+	if l.Unsupported != 0 {
+		parts = append(parts, "no "+l.Unsupported.String())
+	}
+	if l.NoBrightBackground {
+		parts = append(parts, "no bright backgrounds")
+	}
+	if len(parts) == 0 {
+		return "no limits"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Limits of the Linux virtual console and the framebuffer consoles. The
+// Linux console renders italics with colors, and treats the bright
+// backgrounds 100 to 107 like 40 to 47, see console_codes(4). fbterm
+// implements the same sequences. kmscon doesn't render faint text either.
+var (
+	linuxLimits = Limits{
+		Unsupported:        AttrItalic | AttrConceal | AttrStrikethrough | AttrOverline | AttrUnderlineColor,
+		NoBrightBackground: true,
+	}
+	kmsconLimits = Limits{
+		Unsupported: AttrFaint | AttrItalic | AttrConceal | AttrStrikethrough | AttrOverline | AttrUnderlineColor,
+	}
+)
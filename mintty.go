@@ -0,0 +1,118 @@
+package colorprofile
+
+import (
+	"errors"
+	"strings"
+)
+
+// errNotPipe is returned when the output isn't a named pipe.
+var errNotPipe = errors.New("not a named pipe")
+
+// cygwinPty returns the environment of a Cygwin pseudo-terminal pipe name,
+// "Cygwin" or "MSYS2", and whether name is one. Cygwin and MSYS2 terminals
+// such as mintty, which Git Bash uses, aren't Windows consoles: they connect
+// programs to their pseudo-terminals with named pipes, so [term.IsTerminal]
+// fails. The pipes are named like
+// \msys-dd50a72ab4668b33-pty0-to-master, optionally prefixed by
+// \Device\NamedPipe.
+func cygwinPty(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "/", `\`)
+	name = strings.TrimPrefix(name, `\Device\NamedPipe`)
+
+	var env string
+	switch {
+	case strings.HasPrefix(name, `\cygwin-`):
+		env, name = "Cygwin", name[len(`\cygwin-`):]
+	case strings.HasPrefix(name, `\msys-`):
+		env, name = "MSYS2", name[len(`\msys-`):]
+	default:
+		return "", false
+	}
+
+	// The installation key is made of hex digits.
+	key, name, ok := strings.Cut(name, "-")
+	if !ok || len(key) == 0 || strings.Trim(key, "0123456789abcdefABCDEF") != "" {
+		return "", false
+	}
+
+	// pty0-to-master, or pty0-from-master for the input.
+	pty, ok := strings.CutPrefix(name, "pty")
+	if !ok {
+		return "", false
+	}
+	num := strings.TrimLeft(pty, "0123456789")
+	if len(num) == len(pty) {
+		return "", false
+	}
+	switch {
+	case strings.HasPrefix(num, "-to-master"), strings.HasPrefix(num, "-from-master"):
+		return env, true
+	}
+	return "", false
+}
+
+// processPipe reports whether the pipe name is a pipe between processes,
+// such as a shell pipeline, rather than a pseudo-terminal. Cygwin and MSYS2
+// name them like \msys-dd50a72ab4668b33-pipe-0x1, and Windows like
+// \Win32Pipes.00001a2c.00000002.
+func processPipe(name string) bool {
+	name = strings.ReplaceAll(name, "/", `\`)
+	name = strings.TrimPrefix(name, `\Device\NamedPipe`)
+	if strings.HasPrefix(name, `\Win32Pipes.`) {
+		return true
+	}
+	for _, prefix := range []string{`\cygwin-`, `\msys-`} {
+		if rest, ok := strings.CutPrefix(name, prefix); ok {
+			_, rest, _ = strings.Cut(rest, "-")
+			return strings.HasPrefix(rest, "pipe-")
+		}
+	}
+	return false
+}
+
+// cygwinTerminal returns the terminal the environment says the process runs
+// in, mintty or an MSYS2 terminal, if any.
+func cygwinTerminal(env environ) string {
+	switch {
+	case env.get("TERM_PROGRAM") == "mintty":
+		return "mintty"
+	case len(env.get("MSYSTEM")) > 0:
+		return "the MSYS2 " + env.get("MSYSTEM") + " terminal"
+	}
+	return ""
+}
+
+// isCygwinPty reports whether the file descriptor is a Cygwin or MSYS2
+// pseudo-terminal pipe, and explains it. Pipes with other names, e.g. behind
+// winpty, are treated as the pseudo-terminal too if TERM_PROGRAM=mintty or
+// MSYSTEM say the process runs in mintty or MSYS2, unless they're pipes
+// between processes.
+func (s *detection) isCygwinPty(fd uintptr) bool {
+	name, err := s.pipeName(fd)
+	if err != nil {
+		return false
+	}
+	terminal := cygwinTerminal(s.env)
+	env, ok := cygwinPty(name)
+	if !ok {
+		if len(terminal) == 0 || processPipe(name) {
+			return false
+		}
+		s.explainf("output is a named pipe in %s, treating it as its pseudo-terminal", terminal)
+		return true
+	}
+
+	if len(terminal) == 0 {
+		terminal = "a " + env + " terminal"
+	}
+	s.explainf("output is the %s pseudo-terminal pipe of %s", env, terminal)
+	return true
+}
+
+// pipeName returns the name of the named pipe the file descriptor refers to.
+func (d *Detector) pipeName(fd uintptr) (string, error) {
+	if d.PipeName != nil {
+		return d.PipeName(fd)
+	}
+	return pipeName(fd)
+}
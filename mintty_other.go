@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package colorprofile
+
+// pipeName returns an error since Cygwin pseudo-terminal pipes only exist on
+// Windows.
+func pipeName(uintptr) (string, error) {
+	return "", errNotPipe
+}
@@ -0,0 +1,173 @@
+package colorprofile
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCygwinPty(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{`\msys-dd50a72ab4668b33-pty0-to-master`, "MSYS2"},
+		{`\msys-dd50a72ab4668b33-pty12-from-master`, "MSYS2"},
+		{`\cygwin-e022582115c10879-pty4-to-master`, "Cygwin"},
+		{`\Device\NamedPipe\msys-1888ae32e00d56aa-pty0-to-master`, "MSYS2"},
+		{`/msys-dd50a72ab4668b33-pty0-to-master`, "MSYS2"},
+		{`\msys-dd50a72ab4668b33-pty0-to-master-nat`, "MSYS2"},
+		{`\msys-dd50a72ab4668b33-pty-to-master`, ""},
+		{`\msys-dd50a72ab4668b33-pty0-to-slave`, ""},
+		{`\msys-xyz-pty0-to-master`, ""},
+		{`\msys--pty0-to-master`, ""},
+		{`\cygwin-e022582115c10879-pipe-0x1`, ""},
+		{`\mingw-e022582115c10879-pty0-to-master`, ""},
+		{`\Device\NamedPipe\vscode-git-1234`, ""},
+		{`C:\Users\charm\out.txt`, ""},
+		{"", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env, ok := cygwinPty(tc.name)
+			if ok != (len(tc.expected) > 0) || env != tc.expected {
+				t.Errorf("expected %q, got %q, %v", tc.expected, env, ok)
+			}
+		})
+	}
+}
+
+func TestProcessPipe(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected bool
+	}{
+		{`\msys-dd50a72ab4668b33-pipe-0x12`, true},
+		{`\Device\NamedPipe\cygwin-e022582115c10879-pipe-0x1`, true},
+		{`\Device\NamedPipe\Win32Pipes.00001a2c.00000002`, true},
+		{`\msys-dd50a72ab4668b33-pty0-to-master`, false},
+		{`\Device\NamedPipe\winpty-out-1234`, false},
+		{"", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := processPipe(tc.name); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDetectorCygwinPty(t *testing.T) {
+	const pipe = `\Device\NamedPipe\msys-dd50a72ab4668b33-pty0-to-master`
+	cases := []struct {
+		name     string
+		goos     string
+		pipe     string
+		environ  []string
+		expected Profile
+		reason   string
+	}{
+		{
+			name:     "mintty",
+			goos:     "windows",
+			pipe:     pipe,
+			environ:  []string{"TERM=xterm", "TERM_PROGRAM=mintty", "TERM_PROGRAM_VERSION=3.7.4", "MSYSTEM=MINGW64"},
+			expected: TrueColor,
+			reason:   "output is the MSYS2 pseudo-terminal pipe of mintty",
+		},
+		{
+			name:     "MSYS2",
+			goos:     "windows",
+			pipe:     pipe,
+			environ:  []string{"TERM=xterm-256color", "MSYSTEM=UCRT64"},
+			expected: ANSI256,
+			reason:   "output is the MSYS2 pseudo-terminal pipe of the MSYS2 UCRT64 terminal",
+		},
+		{
+			name:     "Cygwin",
+			goos:     "windows",
+			pipe:     `\cygwin-e022582115c10879-pty4-to-master`,
+			environ:  []string{"TERM=xterm-256color"},
+			expected: ANSI256,
+			reason:   "output is the Cygwin pseudo-terminal pipe of a Cygwin terminal",
+		},
+		{
+			name:     "other pipe",
+			goos:     "windows",
+			pipe:     `\Device\NamedPipe\vscode-git-1234`,
+			environ:  []string{"TERM=xterm-256color"},
+			expected: NoTTY,
+		},
+		{
+			name:     "other pipe in mintty",
+			goos:     "windows",
+			pipe:     `\Device\NamedPipe\winpty-out-1234`,
+			environ:  []string{"TERM=xterm", "TERM_PROGRAM=mintty"},
+			expected: TrueColor,
+			reason:   "output is a named pipe in mintty, treating it as its pseudo-terminal",
+		},
+		{
+			name:     "other pipe in MSYS2",
+			goos:     "windows",
+			pipe:     `\Device\NamedPipe\winpty-out-1234`,
+			environ:  []string{"TERM=xterm-256color", "MSYSTEM=MINGW64"},
+			expected: ANSI256,
+			reason:   "output is a named pipe in the MSYS2 MINGW64 terminal, treating it as its pseudo-terminal",
+		},
+		{
+			name:     "MSYS2 pipeline in mintty",
+			goos:     "windows",
+			pipe:     `\Device\NamedPipe\msys-dd50a72ab4668b33-pipe-0x12`,
+			environ:  []string{"TERM=xterm", "TERM_PROGRAM=mintty", "MSYSTEM=MINGW64"},
+			expected: NoTTY,
+		},
+		{
+			name:     "Windows pipeline in MSYS2",
+			goos:     "windows",
+			pipe:     `\Device\NamedPipe\Win32Pipes.00001a2c.00000002`,
+			environ:  []string{"TERM=xterm-256color", "MSYSTEM=UCRT64"},
+			expected: NoTTY,
+		},
+		{
+			name:     "not a pipe",
+			goos:     "windows",
+			environ:  []string{"TERM=xterm", "TERM_PROGRAM=mintty", "MSYSTEM=MINGW64"},
+			expected: NoTTY,
+		},
+		{
+			name:     "not windows",
+			goos:     "linux",
+			pipe:     pipe,
+			environ:  []string{"TERM=xterm", "TERM_PROGRAM=mintty"},
+			expected: NoTTY,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Detector{
+				GOOS:         tc.goos,
+				IsTerminal:   fakeTerminal,
+				LoadTerminfo: noTerminfo,
+				OpenConsole: func(uintptr) (Console, error) {
+					return nil, errNotConsole
+				},
+				PipeName: func(uintptr) (string, error) {
+					if len(tc.pipe) == 0 {
+						return "", errors.New("not a pipe")
+					}
+					return tc.pipe, nil
+				},
+			}
+			e := d.Explain(&fakeFile{fd: 7}, tc.environ)
+			if e.Profile != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, e.Profile)
+			}
+			if len(tc.reason) > 0 && !containsReason(e, tc.reason) {
+				t.Errorf("expected reason %q, got:\n%s", tc.reason, e)
+			}
+		})
+	}
+}
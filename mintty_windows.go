@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+package colorprofile
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipeName returns the name of the named pipe the file descriptor refers to.
+func pipeName(fd uintptr) (string, error) {
+	h := windows.Handle(fd)
+	if t, err := windows.GetFileType(h); err != nil || t != windows.FILE_TYPE_PIPE {
+		return "", errNotPipe
+	}
+
+	// FILE_NAME_INFO is the name length in bytes followed by the UTF-16
+	// name.
+	buf := make([]uint16, 2+windows.MAX_PATH) //nolint:mnd
+	if err := windows.GetFileInformationByHandleEx(h, windows.FileNameInfo,
+		(*byte)(unsafe.Pointer(&buf[0])), uint32(len(buf)*2)); err != nil { //nolint:gosec,mnd
+		return "", err //nolint:wrapcheck
+	}
+	n := *(*uint32)(unsafe.Pointer(&buf[0])) / 2 //nolint:mnd
+	if int(n) > len(buf)-2 {
+		n = uint32(len(buf) - 2) //nolint:gosec
+	}
+	return windows.UTF16ToString(buf[2 : 2+n]), nil
+}
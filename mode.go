@@ -0,0 +1,203 @@
+package colorprofile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// colorModeKind is the kind of a color mode.
+type colorModeKind byte
+
+const (
+	// modeAuto detects the color profile.
+	modeAuto colorModeKind = iota
+	// modeAlways forces colors even if the output isn't a terminal.
+	modeAlways
+	// modeNever disables colors.
+	modeNever
+	// modeProfile uses an explicit color profile.
+	modeProfile
+)
+
+// ColorMode is a color output mode: auto, always, never, or an explicit color
+// profile. The zero value is auto.
+//
+// ColorMode is usually set with a --color command-line flag. It implements
+// [flag.Value] and the pflag.Value interface, so it works with the flag
+// package, pflag, and cobra alike:
+//
+//	var mode colorprofile.ColorMode
+//	flag.Var(&mode, "color", "colorize output: auto, always, never, or a color profile")
+//	flag.Parse()
+//
+//	w := colorprofile.NewWriter(os.Stdout, os.Environ())
+//	w.Profile = mode.Apply(w.Profile)
+//
+// It also implements [encoding.TextMarshaler] and
+// [encoding.TextUnmarshaler], so it can be read from configuration files.
+//
+// Its value is opaque, use the constants below, [ProfileColorMode], or
+// [ParseColorMode] to get color modes.
+type ColorMode uint16
+
+// The kind of a color mode is stored in the high byte, and its color profile
+// in the low byte: the color profile to use for modeProfile, and the minimum
+// color profile for modeAlways.
+const (
+	// ColorAuto detects the color profile. This is the zero value.
+	ColorAuto = ColorMode(modeAuto) << 8
+	// ColorAlways forces colors even if the output isn't a terminal, like
+	// CLICOLOR_FORCE.
+	ColorAlways = ColorMode(modeAlways)<<8 | ColorMode(ANSI)
+	// ColorNever disables colors, like NO_COLOR.
+	ColorNever = ColorMode(modeNever) << 8
+)
+
+// ProfileColorMode returns a color mode using the given color profile
+// regardless of the output and environment.
+func ProfileColorMode(p Profile) ColorMode {
+	return newColorMode(modeProfile, p)
+}
+
+// newColorMode returns a color mode of the given kind and color profile.
+func newColorMode(kind colorModeKind, p Profile) ColorMode {
+	return ColorMode(kind)<<8 | ColorMode(p)
+}
+
+// kind returns the kind of the color mode.
+func (m ColorMode) kind() colorModeKind {
+	return colorModeKind(m >> 8) //nolint:gosec
+}
+
+// profile returns the color profile of the color mode.
+func (m ColorMode) profile() Profile {
+	return Profile(m & 0xff) //nolint:gosec,mnd
+}
+
+// ParseColorMode parses auto, always, never, or a color profile name as
+// accepted by [ParseProfile]. It's case-insensitive.
+func ParseColorMode(s string) (ColorMode, error) {
+	m, ok := parseColorMode(s)
+	if !ok {
+		return ColorAuto, fmt.Errorf("invalid color mode %q: must be auto, always, never, or a color profile", s)
+	}
+	return m, nil
+}
+
+// parseColorMode parses auto, always, never, or a color profile name.
+func parseColorMode(s string) (ColorMode, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "auto":
+		return ColorAuto, true
+	case "always":
+		return ColorAlways, true
+	case "never":
+		return ColorNever, true
+	default:
+		p, err := ParseProfile(s)
+		if err != nil || p == Unknown {
+			return ColorAuto, false
+		}
+		return ProfileColorMode(p), true
+	}
+}
+
+// forceColorMode returns the color mode requested by FORCE_COLOR, following
+// the Node.js convention: 0 disables colors, and 1, 2, and 3 force 16, 256,
+// and true colors. An empty value or true is the same as 1.
+func forceColorMode(env environ) (ColorMode, bool) {
+	v, ok := env.lookup("FORCE_COLOR")
+	if !ok {
+		return ColorAuto, false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "0", "false":
+		return ColorNever, true
+	case "", "1", "true":
+		return ColorAlways, true
+	case "2":
+		return newColorMode(modeAlways, ANSI256), true
+	case "3":
+		return newColorMode(modeAlways, TrueColor), true
+	default:
+		return ColorAuto, false
+	}
+}
+
+// String returns the string representation of a color mode.
+func (m ColorMode) String() string {
+	switch m.kind() {
+	case modeAlways:
+		return "always"
+	case modeNever:
+		return "never"
+	case modeProfile:
+		return profileNames[m.profile()]
+	default:
+		return "auto"
+	}
+}
+
+// Set parses and sets the color mode. It implements [flag.Value].
+func (m *ColorMode) Set(s string) error {
+	mode, err := ParseColorMode(s)
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+// Type returns the type name shown in pflag usage messages.
+func (m ColorMode) Type() string {
+	return "color"
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (m ColorMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (m *ColorMode) UnmarshalText(text []byte) error {
+	return m.Set(string(text))
+}
+
+// Apply returns the detected color profile p adjusted to the color mode.
+// Always upgrades p to at least ANSI, never downgrades it to at most ASCII,
+// and an explicit color profile replaces it.
+func (m ColorMode) Apply(p Profile) Profile {
+	return m.apply(p, p)
+}
+
+// Detect detects the color profile of the output like [Detect], adjusted to
+// the color mode. Unlike [ColorMode.Apply], always takes the environment
+// into account when the output isn't a terminal, e.g. COLORTERM=truecolor
+// forces TrueColor.
+func (m ColorMode) Detect(output io.Writer, env []string) Profile {
+	return (&Detector{Mode: m}).Detect(output, env)
+}
+
+// final reports whether the color mode decides the color profile on its own,
+// without further detection.
+func (m ColorMode) final() bool {
+	return m.kind() == modeNever || m.kind() == modeProfile
+}
+
+// apply returns the color profile p adjusted to the color mode. envp is the
+// color profile inferred from the environment alone, which forced colors can
+// use even if the output isn't a terminal.
+func (m ColorMode) apply(p, envp Profile) Profile {
+	switch m.kind() {
+	case modeAlways:
+		return max(p, envp, m.profile())
+	case modeNever:
+		return min(p, ASCII)
+	case modeProfile:
+		return m.profile()
+	default:
+		return p
+	}
+}
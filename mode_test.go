@@ -0,0 +1,259 @@
+package colorprofile
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestColorOverrides(t *testing.T) {
+	cases := []struct {
+		name      string
+		appPrefix string
+		isatty    bool
+		environ   []string
+		expected  Profile
+	}{
+		{
+			name:      "app never",
+			appPrefix: "MYAPP",
+			isatty:    true,
+			environ:   []string{"TERM=xterm-256color", "MYAPP_COLOR=never"},
+			expected:  ASCII,
+		},
+		{
+			name:      "app never, no tty",
+			appPrefix: "MYAPP",
+			environ:   []string{"TERM=xterm-256color", "MYAPP_COLOR=never"},
+			expected:  NoTTY,
+		},
+		{
+			name:      "app always, no tty",
+			appPrefix: "MYAPP",
+			environ:   []string{"TERM=xterm-256color", "MYAPP_COLOR=always"},
+			expected:  ANSI256,
+		},
+		{
+			name:      "app always, dumb term",
+			appPrefix: "MYAPP",
+			isatty:    true,
+			environ:   []string{"TERM=dumb", "MYAPP_COLOR=ALWAYS"},
+			expected:  ANSI,
+		},
+		{
+			name:      "app truecolor overrides NO_COLOR",
+			appPrefix: "MYAPP",
+			isatty:    true,
+			environ:   []string{"TERM=xterm", "NO_COLOR=1", "MYAPP_COLOR=truecolor"},
+			expected:  TrueColor,
+		},
+		{
+			name:      "app ansi256 downgrades",
+			appPrefix: "MYAPP",
+			isatty:    true,
+			environ:   []string{"TERM=xterm-256color", "COLORTERM=truecolor", "MYAPP_COLOR=ansi256"},
+			expected:  ANSI256,
+		},
+		{
+			name:      "app overrides FORCE_COLOR",
+			appPrefix: "MYAPP",
+			isatty:    true,
+			environ:   []string{"TERM=xterm-256color", "FORCE_COLOR=3", "MYAPP_COLOR=ansi"},
+			expected:  ANSI,
+		},
+		{
+			name:      "app auto falls back to FORCE_COLOR",
+			appPrefix: "MYAPP",
+			isatty:    true,
+			environ:   []string{"TERM=xterm-256color", "FORCE_COLOR=0", "MYAPP_COLOR=auto"},
+			expected:  ASCII,
+		},
+		{
+			name:      "app invalid value",
+			appPrefix: "MYAPP",
+			isatty:    true,
+			environ:   []string{"TERM=xterm-256color", "MYAPP_COLOR=sometimes"},
+			expected:  ANSI256,
+		},
+		{
+			name:     "app without prefix",
+			isatty:   true,
+			environ:  []string{"TERM=xterm-256color", "MYAPP_COLOR=never"},
+			expected: ANSI256,
+		},
+		{
+			name:      "other app",
+			appPrefix: "OTHERAPP",
+			isatty:    true,
+			environ:   []string{"TERM=xterm-256color", "MYAPP_COLOR=never"},
+			expected:  ANSI256,
+		},
+		{
+			name:     "FORCE_COLOR=0",
+			isatty:   true,
+			environ:  []string{"TERM=xterm-256color", "FORCE_COLOR=0", "CLICOLOR_FORCE=1"},
+			expected: ASCII,
+		},
+		{
+			name:     "FORCE_COLOR=false",
+			isatty:   true,
+			environ:  []string{"TERM=xterm-256color", "FORCE_COLOR=false"},
+			expected: ASCII,
+		},
+		{
+			name:     "FORCE_COLOR=1, no tty",
+			environ:  []string{"FORCE_COLOR=1"},
+			expected: ANSI,
+		},
+		{
+			name:     "FORCE_COLOR empty, no tty",
+			environ:  []string{"FORCE_COLOR="},
+			expected: ANSI,
+		},
+		{
+			name:     "FORCE_COLOR=2, no tty",
+			environ:  []string{"FORCE_COLOR=2"},
+			expected: ANSI256,
+		},
+		{
+			name:     "FORCE_COLOR=3, no tty",
+			environ:  []string{"FORCE_COLOR=3"},
+			expected: TrueColor,
+		},
+		{
+			name:     "FORCE_COLOR=1 overrides NO_COLOR",
+			isatty:   true,
+			environ:  []string{"TERM=xterm", "FORCE_COLOR=1", "NO_COLOR=1"},
+			expected: ANSI,
+		},
+		{
+			name:     "FORCE_COLOR=1 is a minimum",
+			environ:  []string{"TERM=xterm", "COLORTERM=truecolor", "FORCE_COLOR=1"},
+			expected: TrueColor,
+		},
+		{
+			name:     "FORCE_COLOR invalid",
+			environ:  []string{"TERM=xterm", "FORCE_COLOR=lots"},
+			expected: NoTTY,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Detector{AppPrefix: tc.appPrefix}
+			if p := d.newDetection(tc.environ).colorProfile(tc.isatty, true); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestColorOverrideSkipsProbing(t *testing.T) {
+	d := Detector{AppPrefix: "MYAPP"}
+	p := d.Detect(io.Discard, []string{"TERM=xterm-direct", "TTY_FORCE=1", "MYAPP_COLOR=ansi"})
+	if p != ANSI {
+		t.Errorf("expected ANSI, got %v", p)
+	}
+}
+
+func TestColorModeFlag(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected ColorMode
+		err      bool
+	}{
+		{args: []string{}, expected: ColorAuto},
+		{args: []string{"--color=auto"}, expected: ColorAuto},
+		{args: []string{"--color=always"}, expected: ColorAlways},
+		{args: []string{"--color", "never"}, expected: ColorNever},
+		{args: []string{"--color=TrueColor"}, expected: ProfileColorMode(TrueColor)},
+		{args: []string{"--color=ansi256"}, expected: ProfileColorMode(ANSI256)},
+		{args: []string{"--color=ascii"}, expected: ProfileColorMode(ASCII)},
+		{args: []string{"--color=sometimes"}, err: true},
+	}
+
+	for _, tc := range cases {
+		var mode ColorMode
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.Var(&mode, "color", "colorize output")
+		err := fs.Parse(tc.args)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%v: expected an error", tc.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.args, err)
+		}
+		if mode != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.args, tc.expected, mode)
+		}
+	}
+}
+
+func TestColorModeText(t *testing.T) {
+	var zero ColorMode
+	if zero != ColorAuto || zero.String() != "auto" {
+		t.Errorf("expected the zero value to be auto, got %v", zero)
+	}
+
+	for _, m := range []ColorMode{
+		ColorAuto, ColorAlways, ColorNever,
+		ProfileColorMode(TrueColor), ProfileColorMode(ANSI256),
+		ProfileColorMode(ANSI), ProfileColorMode(ASCII), ProfileColorMode(NoTTY),
+	} {
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", m, err)
+		}
+		var got ColorMode
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%v: unexpected error: %v", m, err)
+		}
+		if got != m {
+			t.Errorf("expected %v to round-trip, got %v from %s", m, got, b)
+		}
+	}
+}
+
+func TestColorModeApply(t *testing.T) {
+	cases := []struct {
+		mode     ColorMode
+		profile  Profile
+		expected Profile
+	}{
+		{ColorAuto, ANSI256, ANSI256},
+		{ColorAuto, NoTTY, NoTTY},
+		{ColorAlways, NoTTY, ANSI},
+		{ColorAlways, TrueColor, TrueColor},
+		{ColorNever, TrueColor, ASCII},
+		{ColorNever, NoTTY, NoTTY},
+		{ProfileColorMode(ANSI256), TrueColor, ANSI256},
+		{ProfileColorMode(TrueColor), NoTTY, TrueColor},
+	}
+
+	for _, tc := range cases {
+		if p := tc.mode.Apply(tc.profile); p != tc.expected {
+			t.Errorf("%v.Apply(%v): expected %v, got %v", tc.mode, tc.profile, tc.expected, p)
+		}
+	}
+}
+
+func TestColorModeDetect(t *testing.T) {
+	env := []string{"TERM=xterm-256color", "COLORTERM=truecolor", "NO_COLOR=1"}
+	if p := ColorAlways.Detect(io.Discard, env); p != TrueColor {
+		t.Errorf("expected TrueColor, got %v", p)
+	}
+	if p := ColorAuto.Detect(io.Discard, env); p != NoTTY {
+		t.Errorf("expected NoTTY, got %v", p)
+	}
+
+	// The mode takes precedence over the application variable.
+	d := Detector{Mode: ColorNever, AppPrefix: "MYAPP"}
+	if p := d.Env([]string{"TERM=xterm", "MYAPP_COLOR=always"}); p != ASCII {
+		t.Errorf("expected ASCII, got %v", p)
+	}
+}
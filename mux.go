@@ -0,0 +1,186 @@
+package colorprofile
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// Multiplexer describes a terminal multiplexer sitting between the
+// application and the terminal emulator.
+type Multiplexer struct {
+	// Name is the multiplexer name: "tmux", "screen", or "zellij".
+	Name string
+	// Version is the multiplexer version, or empty if it's unknown.
+	Version string
+	// Profile is the best color profile the multiplexer passes through to
+	// the terminal it runs in.
+	Profile Profile
+}
+
+// Multiplexers returns the terminal multiplexers the process is running
+// under, innermost first. The environment only tells which multiplexers are
+// present, so beyond the innermost one the order is a best guess. It doesn't
+// affect the effective profile, which is the minimum across all layers.
+//
+// Multiplexers may run the multiplexer binaries to find out their version
+// and configuration.
+//
+// Note that multiplexers running on the other side of an SSH connection
+// can't be seen, since their environment variables aren't forwarded.
+func Multiplexers(env []string) []Multiplexer {
+	return multiplexers(newEnviron(env), execCommand)
+}
+
+// commandRunner runs the named program and returns its standard output.
+type commandRunner func(name string, args ...string) ([]byte, error)
+
+// execCommand runs the named program using [exec.Command].
+func execCommand(name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(context.Background(), name, args...).Output() //nolint:wrapcheck
+}
+
+// multiplexers returns the multiplexer chain described by the environment.
+// If run is nil, the multiplexers aren't probed and only what the
+// environment tells is used.
+func multiplexers(env environ, run commandRunner) []Multiplexer {
+	var muxes []Multiplexer
+	if tmux, ok := env.lookup("TMUX"); ok && len(tmux) > 0 {
+		version := tmuxVersion(env)
+		var info []byte
+		if run != nil {
+			info, _ = run("tmux", "info")
+		}
+		muxes = append(muxes, Multiplexer{
+			Name:    "tmux",
+			Version: version,
+			Profile: tmuxProfile(version, info),
+		})
+	}
+
+	if sty, ok := env.lookup("STY"); ok && len(sty) > 0 {
+		var version string
+		if run != nil {
+			out, _ := run("screen", "-v")
+			version = parseScreenVersion(out)
+		}
+		muxes = append(muxes, Multiplexer{
+			Name:    "screen",
+			Version: version,
+			Profile: screenProfile(version),
+		})
+	}
+
+	if _, ok := env.lookup("ZELLIJ"); ok || len(env.get("ZELLIJ_SESSION_NAME")) > 0 {
+		// Zellij passes true colors through regardless of its version.
+		muxes = append(muxes, Multiplexer{
+			Name:    "zellij",
+			Profile: TrueColor,
+		})
+	}
+
+	// Move the multiplexer TERM or TERM_PROGRAM refer to to the front, it's
+	// the one we're talking to.
+	inner := env.get("TERM_PROGRAM")
+	if inner != "tmux" {
+		inner = multiplexerTerm(env.get("TERM"))
+	}
+	for i, m := range muxes {
+		if m.Name == inner {
+			copy(muxes[1:i+1], muxes[:i])
+			muxes[0] = m
+			break
+		}
+	}
+
+	return muxes
+}
+
+// tmuxVersion returns the tmux version from the environment, if known.
+func tmuxVersion(env environ) string {
+	if env.get("TERM_PROGRAM") != "tmux" {
+		return ""
+	}
+	// tmux 3.2 and later export TERM_PROGRAM and TERM_PROGRAM_VERSION.
+	// Development builds report versions such as "next-3.4".
+	return strings.TrimPrefix(env.get("TERM_PROGRAM_VERSION"), "next-")
+}
+
+// multiplexerTerm returns the name of the multiplexer the TERM value belongs
+// to, or an empty string if it isn't a multiplexer TERM.
+func multiplexerTerm(term string) string {
+	switch {
+	case inFamily(term, "tmux"):
+		return "tmux"
+	case inFamily(term, "screen"):
+		return "screen"
+	default:
+		return ""
+	}
+}
+
+// multiplexersProfile returns the effective color profile p when running
+// under the given multiplexers.
+func multiplexersProfile(p Profile, term string, muxes []Multiplexer) Profile {
+	if len(muxes) == 0 {
+		return p
+	}
+
+	if multiplexerTerm(term) != "" {
+		// TERM describes the innermost multiplexer and not the terminal, the
+		// multiplexer layer knows better what the terminal supports.
+		p = TrueColor
+	}
+
+	for _, m := range muxes {
+		p = min(p, m.Profile)
+	}
+
+	return p
+}
+
+// tmuxProfile returns the color profile tmux passes through based on its
+// version and `tmux info` output.
+func tmuxProfile(version string, info []byte) Profile {
+	if versionBefore(version, "2.2") {
+		// No TrueColor support before tmux 2.2
+		return ANSI256
+	}
+
+	// Check if tmux has either Tc or RGB capabilities. Otherwise, return
+	// ANSI256.
+	for line := range bytes.SplitSeq(info, []byte("\n")) {
+		if (bytes.Contains(line, []byte("Tc")) || bytes.Contains(line, []byte("RGB"))) &&
+			bytes.Contains(line, []byte("true")) {
+			return TrueColor
+		}
+	}
+
+	return ANSI256
+}
+
+// screenProfile returns the color profile GNU Screen passes through based on
+// its version.
+func screenProfile(version string) Profile {
+	if versionBefore(version, "4") {
+		// No 256 color support before Screen 4
+		return ANSI
+	}
+
+	// Screen 5 can pass true colors through, but only after enabling the
+	// "truecolor" option, which is off by default.
+	return ANSI256
+}
+
+// parseScreenVersion returns the version number from `screen -v` output, e.g.
+// "Screen version 4.09.01 (GNU) 20-Aug-23".
+func parseScreenVersion(out []byte) string {
+	fields := strings.Fields(string(out))
+	for i, f := range fields {
+		if f == "version" && i+1 < len(fields) {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
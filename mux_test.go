@@ -0,0 +1,242 @@
+package colorprofile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var errNotFound = errors.New("not found")
+
+// fakeRunner returns a command runner that answers with the given outputs,
+// keyed by program name.
+func fakeRunner(outputs map[string]string) commandRunner {
+	return func(name string, _ ...string) ([]byte, error) {
+		out, ok := outputs[name]
+		if !ok {
+			return nil, errNotFound
+		}
+		return []byte(out), nil
+	}
+}
+
+const tmuxInfoTc = ` 196: Tc: (flag) true
+ 197: RGB: [missing]
+`
+
+func TestMultiplexers(t *testing.T) {
+	cases := []struct {
+		name     string
+		environ  []string
+		outputs  map[string]string
+		expected []Multiplexer
+	}{
+		{
+			name:    "none",
+			environ: []string{"TERM=xterm-256color"},
+		},
+		{
+			name:    "tmux without Tc",
+			environ: []string{"TERM=tmux-256color", "TMUX=/tmp/tmux-1000/default,1,0"},
+			outputs: map[string]string{"tmux": " 196: Tc: [missing]\n"},
+			expected: []Multiplexer{
+				{Name: "tmux", Profile: ANSI256},
+			},
+		},
+		{
+			name:    "tmux with Tc",
+			environ: []string{"TERM=tmux-256color", "TMUX=/tmp/tmux-1000/default,1,0"},
+			outputs: map[string]string{"tmux": tmuxInfoTc},
+			expected: []Multiplexer{
+				{Name: "tmux", Profile: TrueColor},
+			},
+		},
+		{
+			name: "tmux with version",
+			environ: []string{
+				"TERM=tmux-256color", "TMUX=/tmp/tmux-1000/default,1,0",
+				"TERM_PROGRAM=tmux", "TERM_PROGRAM_VERSION=3.3a",
+			},
+			outputs: map[string]string{"tmux": tmuxInfoTc},
+			expected: []Multiplexer{
+				{Name: "tmux", Version: "3.3a", Profile: TrueColor},
+			},
+		},
+		{
+			name: "tmux development build",
+			environ: []string{
+				"TERM=tmux-256color", "TMUX=/tmp/tmux-1000/default,1,0",
+				"TERM_PROGRAM=tmux", "TERM_PROGRAM_VERSION=next-3.4",
+			},
+			outputs: map[string]string{"tmux": tmuxInfoTc},
+			expected: []Multiplexer{
+				{Name: "tmux", Version: "3.4", Profile: TrueColor},
+			},
+		},
+		{
+			name: "tmux before 2.2",
+			environ: []string{
+				"TERM=screen", "TMUX=/tmp/tmux-1000/default,1,0",
+				"TERM_PROGRAM=tmux", "TERM_PROGRAM_VERSION=2.1",
+			},
+			outputs: map[string]string{"tmux": tmuxInfoTc},
+			expected: []Multiplexer{
+				{Name: "tmux", Version: "2.1", Profile: ANSI256},
+			},
+		},
+		{
+			name:    "tmux unavailable",
+			environ: []string{"TERM=screen", "TMUX=/tmp/tmux-1000/default,1,0"},
+			expected: []Multiplexer{
+				{Name: "tmux", Profile: ANSI256},
+			},
+		},
+		{
+			name:    "screen",
+			environ: []string{"TERM=screen", "STY=1234.pts-0.host"},
+			outputs: map[string]string{"screen": "Screen version 4.09.01 (GNU) 20-Aug-23\n"},
+			expected: []Multiplexer{
+				{Name: "screen", Version: "4.09.01", Profile: ANSI256},
+			},
+		},
+		{
+			name:    "old screen",
+			environ: []string{"TERM=screen", "STY=1234.pts-0.host"},
+			outputs: map[string]string{"screen": "Screen version 3.09.15 (FAU) 23-Feb-03\n"},
+			expected: []Multiplexer{
+				{Name: "screen", Version: "3.09.15", Profile: ANSI},
+			},
+		},
+		{
+			name:    "zellij",
+			environ: []string{"TERM=xterm-256color", "ZELLIJ=0", "ZELLIJ_SESSION_NAME=cheerful-cat"},
+			expected: []Multiplexer{
+				{Name: "zellij", Profile: TrueColor},
+			},
+		},
+		{
+			name: "tmux inside screen",
+			environ: []string{
+				"TERM=tmux-256color", "TMUX=/tmp/tmux-1000/default,1,0",
+				"STY=1234.pts-0.host",
+			},
+			outputs: map[string]string{
+				"tmux":   tmuxInfoTc,
+				"screen": "Screen version 4.09.01 (GNU) 20-Aug-23\n",
+			},
+			expected: []Multiplexer{
+				{Name: "tmux", Profile: TrueColor},
+				{Name: "screen", Version: "4.09.01", Profile: ANSI256},
+			},
+		},
+		{
+			name: "screen inside tmux",
+			environ: []string{
+				"TERM=screen-256color", "TMUX=/tmp/tmux-1000/default,1,0",
+				"STY=1234.pts-0.host",
+			},
+			outputs: map[string]string{
+				"tmux":   tmuxInfoTc,
+				"screen": "Screen version 4.09.01 (GNU) 20-Aug-23\n",
+			},
+			expected: []Multiplexer{
+				{Name: "screen", Version: "4.09.01", Profile: ANSI256},
+				{Name: "tmux", Profile: TrueColor},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			muxes := multiplexers(newEnviron(tc.environ), fakeRunner(tc.outputs))
+			if !reflect.DeepEqual(muxes, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, muxes)
+			}
+		})
+	}
+}
+
+func TestMultiplexersProfile(t *testing.T) {
+	cases := []struct {
+		name     string
+		profile  Profile
+		term     string
+		muxes    []Multiplexer
+		expected Profile
+	}{
+		{
+			name:     "no multiplexers",
+			profile:  TrueColor,
+			term:     "xterm-256color",
+			expected: TrueColor,
+		},
+		{
+			name:     "tmux passes true colors",
+			profile:  ANSI256,
+			term:     "tmux-256color",
+			muxes:    []Multiplexer{{Name: "tmux", Profile: TrueColor}},
+			expected: TrueColor,
+		},
+		{
+			name:     "tmux without Tc, TERM overridden",
+			profile:  TrueColor,
+			term:     "xterm-256color",
+			muxes:    []Multiplexer{{Name: "tmux", Profile: ANSI256}},
+			expected: ANSI256,
+		},
+		{
+			name:    "tmux inside screen",
+			profile: ANSI256,
+			term:    "tmux-256color",
+			muxes: []Multiplexer{
+				{Name: "tmux", Profile: TrueColor},
+				{Name: "screen", Profile: ANSI256},
+			},
+			expected: ANSI256,
+		},
+		{
+			name:     "zellij in a 256 color terminal",
+			profile:  ANSI256,
+			term:     "xterm-256color",
+			muxes:    []Multiplexer{{Name: "zellij", Profile: TrueColor}},
+			expected: ANSI256,
+		},
+		{
+			name:     "zellij in a true color terminal",
+			profile:  TrueColor,
+			term:     "xterm-256color",
+			muxes:    []Multiplexer{{Name: "zellij", Profile: TrueColor}},
+			expected: TrueColor,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if p := multiplexersProfile(tc.profile, tc.term, tc.muxes); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	cases := []struct {
+		version, minimum string
+		expected         bool
+	}{
+		{"3.3a", "2.2", true},
+		{"2.2", "2.2", true},
+		{"2.1", "2.2", false},
+		{"2.10", "2.2", true},
+		{"4.09.01", "4", true},
+		{"3.09.15", "4", false},
+		{"next-3.4", "2.2", false},
+		{"", "1", false},
+	}
+
+	for _, tc := range cases {
+		if got := versionAtLeast(tc.version, tc.minimum); got != tc.expected {
+			t.Errorf("versionAtLeast(%q, %q): expected %v, got %v", tc.version, tc.minimum, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package colorprofile
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultPager is the pager used when PAGER isn't set.
+const defaultPager = "less -R"
+
+// Pager is a pager process, such as less, the output is piped into. Its
+// [Writer] downsamples the colors to the terminal the pager runs in, or
+// strips them if the pager doesn't pass escape sequences through.
+type Pager struct {
+	*Writer
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// NewPager starts the pager of the PAGER environment variable, or less -R if
+// it's not set, writing to output, usually [os.Stdout]. See
+// [Detector.Pager].
+func NewPager(output io.Writer, env []string) (*Pager, error) {
+	return new(Detector).Pager(newEnviron(env).get("PAGER"), output, env)
+}
+
+// Pager starts the pager command line, e.g. "less -R", writing to output,
+// usually [os.Stdout], with the environment env. If cmdline is empty, less -R
+// is used. The command line is split on spaces, without shell quoting.
+//
+// The color profile is detected for output, and is NoTTY if the pager
+// doesn't pass raw escape sequences through. See [RawPager]. Like
+// [Detector.NewWriter], the writer degrades the styles the terminal doesn't
+// render. It doesn't translate them for legacy Windows consoles though: the
+// pager draws the text, so it gets the SGR sequences, and renders them as it
+// can.
+func (d *Detector) Pager(cmdline string, output io.Writer, env []string) (*Pager, error) {
+	if len(strings.TrimSpace(cmdline)) == 0 {
+		cmdline = defaultPager
+	}
+	args := strings.Fields(cmdline)
+
+	s := d.newDetection(env)
+	p := s.detect(output)
+	if !RawPager(cmdline, env) {
+		p = NoTTY
+	}
+
+	cmd := exec.CommandContext(context.Background(), args[0], args[1:]...) //nolint:gosec
+	cmd.Stdout = output
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &Pager{
+		Writer: &Writer{Forward: newOutput(stdin, nil, s.limits), Profile: p},
+		cmd:    cmd,
+		stdin:  stdin,
+	}, nil
+}
+
+// Close closes the input of the pager, and waits for the user to quit it.
+func (p *Pager) Close() error {
+	if err := p.stdin.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		_ = p.cmd.Wait()
+		return err //nolint:wrapcheck
+	}
+	return p.cmd.Wait() //nolint:wrapcheck
+}
+
+// RawPager reports whether the pager command line passes raw escape
+// sequences through, so colors can be written to it. That's the case of
+// less with -R, -r, or the same options in the LESS environment variable,
+// bat, most, and cat. Other pagers, including more, show escape sequences
+// as is or strip them.
+func RawPager(cmdline string, env []string) bool {
+	args := strings.Fields(cmdline)
+	if len(args) == 0 {
+		return false
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(args[0])), ".exe")
+	switch name {
+	case "bat", "batcat", "most", "cat":
+		return true
+	case "less":
+		// Options on the command line take precedence over LESS.
+		raw, ok := lessRawOption(args[1:], false)
+		if !ok {
+			raw, _ = lessRawOption(strings.Fields(newEnviron(env).get("LESS")), true)
+		}
+		return raw
+	}
+	return false
+}
+
+// lessOptionsWithArgs are the less options taking an argument, which is the
+// rest of the option cluster, e.g. -x4 or -Pprompt.
+const lessOptionsWithArgs = "bhjkoOpPtTxyz#D"
+
+// lessRawOption returns whether less options enable raw control characters,
+// and whether they set them at all. Options in LESS may omit the leading
+// dash, e.g. LESS=FRX.
+func lessRawOption(args []string, env bool) (raw, ok bool) {
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			return //nolint:nakedret
+		case arg == "--raw-control-chars", arg == "--RAW-CONTROL-CHARS":
+			raw, ok = true, true
+			continue
+		case strings.HasPrefix(arg, "--"):
+			continue
+		case strings.HasPrefix(arg, "-+"):
+			// -+R resets the option to its default, i.e. off.
+			if strings.ContainsAny(arg[2:], "rR") {
+				raw, ok = false, true
+			}
+			continue
+		case strings.HasPrefix(arg, "-"):
+			arg = arg[1:]
+		case !env:
+			// A file name or a +command.
+			continue
+		}
+
+		for _, c := range arg {
+			if c == 'r' || c == 'R' {
+				raw, ok = true, true
+			}
+			if strings.ContainsRune(lessOptionsWithArgs, c) {
+				break
+			}
+		}
+	}
+	return //nolint:nakedret
+}
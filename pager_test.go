@@ -0,0 +1,208 @@
+package colorprofile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRawPager(t *testing.T) {
+	cases := []struct {
+		name     string
+		cmdline  string
+		environ  []string
+		expected bool
+	}{
+		{"less", "less", nil, false},
+		{"less -R", "less -R", nil, true},
+		{"less -r", "less -r", nil, true},
+		{"less cluster", "less -FRX", nil, true},
+		{"less long option", "less --RAW-CONTROL-CHARS", nil, true},
+		{"less other long option", "less --mouse --quit-if-one-screen", nil, false},
+		{"less option argument", "less -Pprompt", nil, false},
+		{"less option argument before R", "less -x4R", nil, false},
+		{"less path", "/usr/bin/less -R", nil, true},
+		{"LESS", "less", []string{"LESS=FRX"}, true},
+		{"LESS with dash", "less", []string{"LESS=-F -R"}, true},
+		{"LESS without R", "less", []string{"LESS=FX"}, false},
+		{"LESS reset", "less -+R", []string{"LESS=FRX"}, false},
+		{"LESS reset in LESS", "less", []string{"LESS=-R -+R"}, false},
+		{"command line takes precedence", "less -R", []string{"LESS=-+R"}, true},
+		{"file name", "less raw.txt", nil, false},
+		{"bat", "bat --style=plain", nil, true},
+		{"batcat", "batcat", nil, true},
+		{"most", "most", nil, true},
+		{"cat", "cat", nil, true},
+		{"more", "more", nil, false},
+		{"more with LESS", "more", []string{"LESS=R"}, false},
+		{"empty", "", nil, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if raw := RawPager(tc.cmdline, tc.environ); raw != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, raw)
+			}
+		})
+	}
+}
+
+// TestPagerHelperProcess isn't a real test. It's a fake pager the pager
+// tests run, which writes its arguments and copies its input to its output.
+func TestPagerHelperProcess(t *testing.T) {
+	if os.Getenv("COLORPROFILE_PAGER_HELPER") != "1" {
+		t.Skip("not a pager")
+	}
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	_, _ = io.WriteString(os.Stdout, strings.Join(args, " ")+"\n")
+	_, _ = io.Copy(os.Stdout, os.Stdin)
+	os.Exit(0)
+}
+
+// fakePager returns the path of a fake pager program with the given name,
+// which runs TestPagerHelperProcess.
+func fakePager(t *testing.T, name string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake pager is a shell script")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("can't find the test binary: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	script := "#!/bin/sh\nCOLORPROFILE_PAGER_HELPER=1 exec '" + exe + "' -test.run='^TestPagerHelperProcess$' -- \"$@\"\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil { //nolint:gosec
+		t.Fatalf("can't write the fake pager: %v", err)
+	}
+	return path
+}
+
+func TestPager(t *testing.T) {
+	less := fakePager(t, "less")
+	cases := []struct {
+		name     string
+		cmdline  string
+		environ  []string
+		profile  Profile
+		input    string
+		expected string
+	}{
+		{
+			name:     "raw",
+			cmdline:  less + " -R",
+			environ:  []string{"TERM=xterm-256color"},
+			profile:  ANSI256,
+			expected: "-R\n\x1b[38;5;63mhello\x1b[m\n",
+		},
+		{
+			name:     "raw with LESS",
+			cmdline:  less,
+			environ:  []string{"TERM=xterm-256color", "LESS=FRX"},
+			profile:  ANSI256,
+			expected: "\n\x1b[38;5;63mhello\x1b[m\n",
+		},
+		{
+			name:     "not raw",
+			cmdline:  less + " -F",
+			environ:  []string{"TERM=xterm-256color"},
+			profile:  NoTTY,
+			expected: "-F\nhello\n",
+		},
+		{
+			name:     "Linux console",
+			cmdline:  less + " -R",
+			environ:  []string{"TERM=linux"},
+			profile:  ANSI,
+			input:    "\x1b[3;101mhello\x1b[m\n",
+			expected: "-R\n\x1b[41mhello\x1b[m\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo, RunCommand: fakeRunner(nil)}
+			out := &fakeFile{fd: 42}
+			p, err := d.Pager(tc.cmdline, out, tc.environ)
+			if err != nil {
+				t.Fatalf("can't start the pager: %v", err)
+			}
+			if p.Profile != tc.profile {
+				t.Errorf("expected %v, got %v", tc.profile, p.Profile)
+			}
+			input := tc.input
+			if len(input) == 0 {
+				input = "\x1b[38;2;107;80;255mhello\x1b[m\n"
+			}
+			if _, err := io.WriteString(p, input); err != nil {
+				t.Fatalf("can't write to the pager: %v", err)
+			}
+			if err := p.Close(); err != nil {
+				t.Fatalf("pager failed: %v", err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestNewPager(t *testing.T) {
+	pager := fakePager(t, "bat")
+	p, err := NewPager(io.Discard, []string{"PAGER=" + pager + " --plain"})
+	if err != nil {
+		t.Fatalf("can't start the pager: %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("pager failed: %v", err)
+	}
+
+	if _, err := NewPager(io.Discard, []string{"PAGER=" + filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("expected an error for a missing pager")
+	}
+}
+
+func TestPagerConsole(t *testing.T) {
+	less := fakePager(t, "less")
+	c := &legacyConsole{fakeConsole: fakeConsole{mode: 0x3}}
+	d := Detector{
+		GOOS:         "windows",
+		IsTerminal:   fakeTerminal,
+		LoadTerminfo: noTerminfo,
+		WindowsVersion: func() (uint32, uint32) {
+			return 10, 15063
+		},
+		OpenConsole: func(uintptr) (Console, error) {
+			return c, nil
+		},
+	}
+	out := &fakeFile{fd: 42}
+	p, err := d.Pager(less+" -R", out, nil)
+	if err != nil {
+		t.Fatalf("can't start the pager: %v", err)
+	}
+	if _, err := io.WriteString(p, "\x1b[1;31mhello\x1b[m\n"); err != nil {
+		t.Fatalf("can't write to the pager: %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("pager failed: %v", err)
+	}
+
+	// The pager gets the SGR sequences, the console isn't changed behind its
+	// back.
+	if expected := "-R\n\x1b[1;31mhello\x1b[m\n"; out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+	if len(c.ops) > 0 {
+		t.Errorf("expected no console attributes, got %v", c.ops)
+	}
+}
@@ -1,7 +1,10 @@
 package colorprofile
 
 import (
+	"encoding/json"
+	"fmt"
 	"image/color"
+	"strings"
 	"sync"
 
 	"github.com/charmbracelet/x/ansi"
@@ -46,6 +49,91 @@ func (p Profile) String() string {
 	}
 }
 
+// profileNames are the canonical text names of the profiles, as used by
+// [Profile.MarshalText].
+var profileNames = map[Profile]string{
+	Unknown:   "unknown",
+	NoTTY:     "notty",
+	ASCII:     "ascii",
+	ANSI:      "ansi",
+	ANSI256:   "ansi256",
+	TrueColor: "truecolor",
+}
+
+// profileAliases are the alternative names accepted by [ParseProfile].
+var profileAliases = map[string]Profile{
+	"24bit":      TrueColor,
+	"24-bit":     TrueColor,
+	"256":        ANSI256,
+	"256color":   ANSI256,
+	"8bit":       ANSI256,
+	"8-bit":      ANSI256,
+	"16":         ANSI,
+	"16color":    ANSI,
+	"4bit":       ANSI,
+	"4-bit":      ANSI,
+	"mono":       ASCII,
+	"monochrome": ASCII,
+	"none":       NoTTY,
+}
+
+// ParseProfile parses a color profile name. It's case-insensitive, and
+// accepts the names returned by [Profile.String] and [Profile.MarshalText],
+// as well as the aliases truecolor, 24bit, 256, 16, mono, and none.
+func ParseProfile(s string) (Profile, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for p, n := range profileNames {
+		if name == n {
+			return p, nil
+		}
+	}
+	if p, ok := profileAliases[name]; ok {
+		return p, nil
+	}
+	return Unknown, fmt.Errorf("invalid color profile %q", s)
+}
+
+// MarshalText implements [encoding.TextMarshaler]. It returns the lowercase
+// profile name, e.g. "truecolor" or "ansi256".
+func (p Profile) MarshalText() ([]byte, error) {
+	name, ok := profileNames[p]
+	if !ok {
+		return nil, fmt.Errorf("invalid color profile %d", p)
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler]. See [ParseProfile]
+// for the accepted names.
+func (p *Profile) UnmarshalText(text []byte) error {
+	profile, err := ParseProfile(string(text))
+	if err != nil {
+		return err
+	}
+	*p = profile
+	return nil
+}
+
+// MarshalJSON implements [json.Marshaler]. Profiles are encoded as JSON
+// strings, see [Profile.MarshalText].
+func (p Profile) MarshalJSON() ([]byte, error) {
+	text, err := p.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text)) //nolint:wrapcheck
+}
+
+// UnmarshalJSON implements [json.Unmarshaler]. It accepts a JSON string, see
+// [ParseProfile] for the accepted names.
+func (p *Profile) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("color profile must be a string: %w", err)
+	}
+	return p.UnmarshalText([]byte(s))
+}
+
 var (
 	cache = map[Profile]map[color.Color]color.Color{
 		ANSI256: {},
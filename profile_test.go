@@ -1,6 +1,7 @@
 package colorprofile
 
 import (
+	"encoding/json"
 	"image/color"
 	"log"
 	"testing"
@@ -191,3 +192,104 @@ func TestCache(t *testing.T) {
 		})
 	}
 }
+
+func TestParseProfile(t *testing.T) {
+	testCases := map[string]struct {
+		input    string
+		expected Profile
+		err      bool
+	}{
+		"truecolor":      {input: "truecolor", expected: TrueColor},
+		"TrueColor":      {input: "TrueColor", expected: TrueColor},
+		"24bit":          {input: "24bit", expected: TrueColor},
+		"ansi256":        {input: "ansi256", expected: ANSI256},
+		"256":            {input: "256", expected: ANSI256},
+		"ANSI":           {input: "ANSI", expected: ANSI},
+		"16":             {input: "16", expected: ANSI},
+		"Ascii":          {input: "Ascii", expected: ASCII},
+		"ASCII":          {input: "ASCII", expected: ASCII},
+		"mono":           {input: "mono", expected: ASCII},
+		"NoTTY":          {input: "NoTTY", expected: NoTTY},
+		"none":           {input: "none", expected: NoTTY},
+		"unknown":        {input: "unknown", expected: Unknown},
+		"surrounding ws": {input: " ansi256\n", expected: ANSI256},
+		"empty":          {input: "", err: true},
+		"invalid":        {input: "lots", err: true},
+	}
+
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			p, err := ParseProfile(testCase.input)
+			if testCase.err {
+				if err == nil {
+					t.Errorf("Expected an error parsing %q, but instead received %s", testCase.input, p)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error parsing %q: %v", testCase.input, err)
+			}
+			if p != testCase.expected {
+				t.Errorf("Expected %q to parse as %s, but instead received %s", testCase.input, testCase.expected, p)
+			}
+		})
+	}
+}
+
+func TestProfileMarshaling(t *testing.T) {
+	for _, p := range []Profile{Unknown, NoTTY, ASCII, ANSI, ANSI256, TrueColor} {
+		// String output parses back.
+		if parsed, err := ParseProfile(p.String()); err != nil || parsed != p {
+			t.Errorf("Expected %s to round-trip through String, but instead received %s (%v)", p, parsed, err)
+		}
+
+		text, err := p.MarshalText()
+		if err != nil {
+			t.Fatalf("Unexpected error marshaling %s: %v", p, err)
+		}
+		var fromText Profile
+		if err := fromText.UnmarshalText(text); err != nil || fromText != p {
+			t.Errorf("Expected %s to round-trip through text %q, but instead received %s (%v)", p, text, fromText, err)
+		}
+
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("Unexpected error marshaling %s to JSON: %v", p, err)
+		}
+		var fromJSON Profile
+		if err := json.Unmarshal(b, &fromJSON); err != nil || fromJSON != p {
+			t.Errorf("Expected %s to round-trip through JSON %s, but instead received %s (%v)", p, b, fromJSON, err)
+		}
+	}
+
+	if _, err := Profile(42).MarshalText(); err == nil {
+		t.Errorf("Expected an error marshaling an invalid profile")
+	}
+}
+
+func TestProfileConfig(t *testing.T) {
+	var config struct {
+		Profile  Profile            `json:"profile"`
+		Profiles map[string]Profile `json:"profiles"`
+	}
+
+	input := `{"profile": "256", "profiles": {"xterm": "16", "kitty": "24bit"}}`
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Profile != ANSI256 || config.Profiles["xterm"] != ANSI || config.Profiles["kitty"] != TrueColor {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	out, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := `{"profile":"ansi256","profiles":{"kitty":"truecolor","xterm":"ansi"}}`; string(out) != expected {
+		t.Errorf("Expected %s, but instead received %s", expected, out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"profile": 4}`), &config); err == nil {
+		t.Errorf("Expected an error unmarshaling a number")
+	}
+}
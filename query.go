@@ -0,0 +1,147 @@
+package colorprofile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// queryTimeout is how long to wait for the terminal to reply to a query.
+var queryTimeout = 2 * time.Second
+
+// errQueryTimeout is returned when the terminal doesn't reply in time.
+var errQueryTimeout = errors.New("no reply from the terminal")
+
+// queryTrueColor queries the terminal for the RGB and Tc capabilities using
+// XTGETTCAP.
+func (s *detection) queryTrueColor() bool {
+	rw := s.TTY
+	if s.remote {
+		if s.session == nil {
+			s.explainf("no session to query the client terminal with")
+			return false
+		}
+		rw = s.session
+	} else if rw == nil {
+		tty, err := openTTY()
+		if err != nil {
+			s.explainf("can't open the terminal to query it: %v", err)
+			return false
+		}
+		defer tty.Close() //nolint:errcheck
+		rw = tty
+	}
+
+	reply, err := queryTerminal(rw, ansi.XTGETTCAP("RGB", "Tc"))
+	if err != nil {
+		s.explainf("can't query the terminal: %v", err)
+		return false
+	}
+
+	if !hasTermcapReply(reply) {
+		s.explainf("terminal doesn't report the RGB or Tc capabilities")
+		return false
+	}
+
+	s.explainf("terminal reports true color support")
+	return true
+}
+
+// queryTerminal writes query followed by a primary device attributes (DA1)
+// request to the terminal, and returns what it replies up to and including
+// the DA1 reply. All terminals answer DA1, so this doesn't wait for replies
+// to queries the terminal doesn't support.
+//
+// It gives up after queryTimeout, in case the terminal doesn't reply at all
+// or something else reads the replies first. If rw doesn't support
+// deadlines, like SSH sessions, the read is abandoned instead, and consumes
+// the next input of rw when it returns.
+func queryTerminal(rw io.ReadWriter, query string) ([]byte, error) {
+	if d, ok := rw.(interface{ SetDeadline(time.Time) error }); ok {
+		if err := d.SetDeadline(time.Now().Add(queryTimeout)); err == nil {
+			defer d.SetDeadline(time.Time{}) //nolint:errcheck
+			return exchange(rw, query)
+		}
+	}
+
+	type result struct {
+		reply []byte
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		reply, err := exchange(rw, query)
+		done <- result{reply, err}
+	}()
+
+	timer := time.NewTimer(queryTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.reply, r.err
+	case <-timer.C:
+		return nil, errQueryTimeout
+	}
+}
+
+// exchange writes the query and a DA1 request to the terminal, and reads its
+// replies up to and including the DA1 reply.
+func exchange(rw io.ReadWriter, query string) ([]byte, error) {
+	if _, err := io.WriteString(rw, query+ansi.RequestPrimaryDeviceAttributes); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	var reply []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := rw.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if hasPrimaryDeviceAttributes(reply) {
+			return reply, nil
+		}
+		if err != nil {
+			return reply, err //nolint:wrapcheck
+		}
+	}
+}
+
+// hasPrimaryDeviceAttributes reports whether b contains a DA1 reply.
+func hasPrimaryDeviceAttributes(b []byte) bool {
+	return findSequence(b, func(seq []byte, cmd ansi.Cmd, _ *ansi.Parser) bool {
+		return ansi.HasCsiPrefix(seq) && cmd.Prefix() == '?' && cmd.Final() == 'c'
+	})
+}
+
+// hasTermcapReply reports whether b contains a valid XTGETTCAP reply, i.e.
+// the terminal has at least one of the requested capabilities.
+func hasTermcapReply(b []byte) bool {
+	return findSequence(b, func(seq []byte, cmd ansi.Cmd, p *ansi.Parser) bool {
+		if !ansi.HasDcsPrefix(seq) || cmd.Intermediate() != '+' || cmd.Final() != 'r' {
+			return false
+		}
+		valid, _ := p.Param(0, 0)
+		return valid == 1
+	})
+}
+
+// findSequence reports whether b contains an escape sequence for which fn
+// returns true.
+func findSequence(b []byte, fn func(seq []byte, cmd ansi.Cmd, p *ansi.Parser) bool) bool {
+	parser := ansi.GetParser()
+	defer ansi.PutParser(parser)
+
+	var state byte
+	for len(b) > 0 {
+		parser.Reset()
+		seq, _, n, newState := ansi.DecodeSequence(b, state, parser)
+		if bytes.HasPrefix(seq, []byte{ansi.ESC}) && fn(seq, ansi.Cmd(parser.Command()), parser) {
+			return true
+		}
+		b = b[n:]
+		state = newState
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package colorprofile
+
+import "maps"
+
+// SSHPolicy is the policy used to detect the color profile in SSH sessions.
+// SSH clients usually don't forward COLORTERM, so true color terminals get
+// detected as ANSI256 unless told otherwise.
+type SSHPolicy byte
+
+const (
+	// SSHTrustTerm detects the color profile from TERM and the forwarded
+	// environment, like in local sessions.
+	SSHTrustTerm SSHPolicy = iota
+	// SSHLCTerminal consults LC_TERMINAL and LC_TERMINAL_VERSION, which
+	// iTerm2 sets and SSH forwards along with the other LC_* variables. They
+	// are matched against [Terminal.Program] and [Terminal.Version].
+	SSHLCTerminal
+	// SSHQuery queries the terminal for true color support using XTGETTCAP.
+	// Only [Detect] queries the terminal, [Env] falls back to trusting TERM.
+	SSHQuery
+)
+
+// String returns the string representation of an SSHPolicy.
+func (p SSHPolicy) String() string {
+	switch p {
+	case SSHTrustTerm:
+		return "trust TERM"
+	case SSHLCTerminal:
+		return "LC_TERMINAL"
+	case SSHQuery:
+		return "query"
+	default:
+		return "unknown"
+	}
+}
+
+// sshSession reports whether the process runs in an SSH session.
+func sshSession(env environ) bool {
+	return len(env.get("SSH_CONNECTION")) > 0 ||
+		len(env.get("SSH_CLIENT")) > 0 ||
+		len(env.get("SSH_TTY")) > 0
+}
+
+// lcTerminalEnv returns a copy of env with TERM_PROGRAM and
+// TERM_PROGRAM_VERSION set from LC_TERMINAL and LC_TERMINAL_VERSION, if
+// LC_TERMINAL is set.
+func lcTerminalEnv(env environ) (environ, bool) {
+	lcTerm, ok := env.lookup("LC_TERMINAL")
+	if !ok || len(lcTerm) == 0 {
+		return env, false
+	}
+
+	m := maps.Clone(env)
+	m["TERM_PROGRAM"] = lcTerm
+	m["TERM_PROGRAM_VERSION"] = env.get("LC_TERMINAL_VERSION")
+	return m, true
+}
@@ -0,0 +1,169 @@
+package colorprofile
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeTTY is a terminal replying to queries with a canned reply.
+type fakeTTY struct {
+	queries bytes.Buffer
+	reply   *strings.Reader
+}
+
+func newFakeTTY(reply string) *fakeTTY {
+	return &fakeTTY{reply: strings.NewReader(reply)}
+}
+
+func (t *fakeTTY) Write(p []byte) (int, error) { return t.queries.Write(p) }
+func (t *fakeTTY) Read(p []byte) (int, error)  { return t.reply.Read(p) }
+
+const da1Reply = "\x1b[?62;22c"
+
+func TestSSHPolicy(t *testing.T) {
+	sshEnv := []string{
+		"TERM=xterm-256color",
+		"SSH_CONNECTION=10.0.0.2 51234 10.0.0.1 22",
+		"SSH_TTY=/dev/pts/3",
+		"LC_TERMINAL=iTerm2",
+		"LC_TERMINAL_VERSION=3.5.4",
+	}
+
+	cases := []struct {
+		name     string
+		environ  []string
+		policy   SSHPolicy
+		expected Profile
+	}{
+		{
+			name:     "trust TERM",
+			environ:  sshEnv,
+			policy:   SSHTrustTerm,
+			expected: ANSI256,
+		},
+		{
+			name:     "LC_TERMINAL",
+			environ:  sshEnv,
+			policy:   SSHLCTerminal,
+			expected: TrueColor,
+		},
+		{
+			name: "old iTerm2 LC_TERMINAL",
+			environ: []string{
+				"TERM=xterm",
+				"SSH_CONNECTION=10.0.0.2 51234 10.0.0.1 22",
+				"LC_TERMINAL=iTerm2",
+				"LC_TERMINAL_VERSION=2.9",
+			},
+			policy:   SSHLCTerminal,
+			expected: ANSI256,
+		},
+		{
+			name: "LC_TERMINAL without SSH",
+			environ: []string{
+				"TERM=xterm-256color",
+				"LC_TERMINAL=iTerm2",
+				"LC_TERMINAL_VERSION=3.5.4",
+			},
+			policy:   SSHLCTerminal,
+			expected: ANSI256,
+		},
+		{
+			name: "LC_TERMINAL unset",
+			environ: []string{
+				"TERM=xterm-256color",
+				"SSH_CLIENT=10.0.0.2 51234 22",
+			},
+			policy:   SSHLCTerminal,
+			expected: ANSI256,
+		},
+		{
+			name:     "query falls back to TERM",
+			environ:  sshEnv,
+			policy:   SSHQuery,
+			expected: ANSI256,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Detector{SSH: tc.policy}
+			if p := d.Env(tc.environ); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestSSHQuery(t *testing.T) {
+	env := []string{
+		"TERM=xterm-256color",
+		"SSH_CONNECTION=10.0.0.2 51234 10.0.0.1 22",
+		"TTY_FORCE=1",
+	}
+
+	cases := []struct {
+		name     string
+		reply    string
+		expected Profile
+	}{
+		{
+			name:     "RGB",
+			reply:    "\x1bP1+r524742=382F382F38\x1b\\\x1bP0+r5463\x1b\\" + da1Reply,
+			expected: TrueColor,
+		},
+		{
+			name:     "Tc",
+			reply:    "\x1bP0+r524742\x1b\\\x1bP1+r5463\x1b\\" + da1Reply,
+			expected: TrueColor,
+		},
+		{
+			name:     "no capabilities",
+			reply:    "\x1bP0+r524742\x1b\\\x1bP0+r5463\x1b\\" + da1Reply,
+			expected: ANSI256,
+		},
+		{
+			name:     "no XTGETTCAP support",
+			reply:    da1Reply,
+			expected: ANSI256,
+		},
+		{
+			name:     "no reply",
+			expected: ANSI256,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tty := newFakeTTY(tc.reply)
+			d := Detector{SSH: SSHQuery, TTY: tty}
+			e := d.Explain(io.Discard, env)
+			if e.Profile != tc.expected {
+				t.Errorf("expected %v, got %v\n%s", tc.expected, e.Profile, e)
+			}
+			if q := tty.queries.String(); !strings.HasSuffix(q, "\x1b[c") {
+				t.Errorf("expected the query to end with a DA1 request, got %q", q)
+			}
+		})
+	}
+}
+
+func TestExplainSSH(t *testing.T) {
+	e := (&Detector{SSH: SSHLCTerminal}).Explain(io.Discard, []string{
+		"TERM=xterm-256color",
+		"SSH_TTY=/dev/pts/3",
+		"LC_TERMINAL=iTerm2",
+		"LC_TERMINAL_VERSION=3.5.4",
+		"TTY_FORCE=1",
+	})
+	if e.Profile != TrueColor {
+		t.Errorf("expected TrueColor, got %v", e.Profile)
+	}
+	for _, want := range []string{"SSH session", "LC_TERMINAL=iTerm2"} {
+		if !strings.Contains(e.String(), want) {
+			t.Errorf("expected explanation to mention %q, got:\n%s", want, e)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package colorprofile
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Streams are color profile writers for the outputs of a process, usually
+// its standard output and error. They're detected separately, so colored
+// logs written to stderr keep their colors when stdout is piped, e.g. with
+// `mycmd | less`, but share the parsed environment and the results of the
+// probes, such as `tmux info` or terminal queries, which run at most once.
+//
+// Streams is safe for concurrent use, but the writers it returns aren't.
+type Streams struct {
+	// Stdout and Stderr are the writers of the standard output and error.
+	Stdout, Stderr *Writer
+
+	detector *Detector
+	env      environ
+	probes   *probes
+}
+
+// NewStreams returns the writers of the standard output and error of the
+// process, detected with the default detector settings in env, usually
+// os.Environ().
+func NewStreams(env []string) *Streams {
+	return new(Detector).Streams(os.Stdout, os.Stderr, env)
+}
+
+// Streams returns the writers of the given standard output and error,
+// usually [os.Stdout] and [os.Stderr].
+func (d *Detector) Streams(stdout, stderr io.Writer, env []string) *Streams {
+	s := &Streams{
+		detector: d,
+		env:      newEnviron(env),
+		probes:   new(probes),
+	}
+	s.Stdout = s.Writer(stdout)
+	s.Stderr = s.Writer(stderr)
+	return s
+}
+
+// Writer returns a writer for another output in the same environment, e.g.
+// a file descriptor inherited from the parent process.
+func (s *Streams) Writer(w io.Writer) *Writer {
+	return s.detection().writer(w)
+}
+
+// Explain explains the color profile of an output in the same environment.
+func (s *Streams) Explain(w io.Writer) Explanation {
+	d := s.detection()
+	p := d.detect(w)
+	return Explanation{Profile: p, Reasons: d.reasons, Limits: d.limits}
+}
+
+// detection returns a new detection sharing the environment and the probe
+// results of the streams.
+func (s *Streams) detection() *detection {
+	d := s.detector.newEnvironDetection(s.env)
+	d.probes = s.probes
+	return d
+}
+
+// probes caches the results of the probes detections run, so detecting
+// several outputs in the same environment runs them once.
+type probes struct {
+	mu        sync.Mutex
+	commands  map[string]commandResult
+	terminfos map[string]terminfoResult
+	query     *queryResult
+}
+
+// commandResult is the result of a command run to probe multiplexers.
+type commandResult struct {
+	out []byte
+	err error
+}
+
+// terminfoResult is the result of a terminfo entry lookup.
+type terminfoResult struct {
+	caps   TerminfoCaps
+	source string
+	ok     bool
+}
+
+// queryResult is the result of a terminal query, and the reasons explaining
+// it.
+type queryResult struct {
+	trueColor bool
+	reasons   []string
+}
+
+// runner returns a command runner running each command once with run.
+func (p *probes) runner(run commandRunner) commandRunner {
+	return func(name string, args ...string) ([]byte, error) {
+		key := strings.Join(append([]string{name}, args...), "\x00")
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if r, ok := p.commands[key]; ok {
+			return r.out, r.err
+		}
+		out, err := run(name, args...)
+		if p.commands == nil {
+			p.commands = make(map[string]commandResult)
+		}
+		p.commands[key] = commandResult{out, err}
+		return out, err
+	}
+}
+
+// terminfo returns the terminfo entry of term, loading it once with load.
+func (p *probes) terminfo(term string, load func(string) (TerminfoCaps, string, bool)) (TerminfoCaps, string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if r, ok := p.terminfos[term]; ok {
+		return r.caps, r.source, r.ok
+	}
+	caps, source, ok := load(term)
+	if p.terminfos == nil {
+		p.terminfos = make(map[string]terminfoResult)
+	}
+	p.terminfos[term] = terminfoResult{caps, source, ok}
+	return caps, source, ok
+}
+
+// cachedQueryTrueColor queries the terminal for true color support, once
+// per environment if the detection shares probe results.
+func (s *detection) cachedQueryTrueColor() bool {
+	if s.probes == nil {
+		return s.queryTrueColor()
+	}
+
+	p := s.probes
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.query == nil {
+		n := len(s.reasons)
+		trueColor := s.queryTrueColor()
+		p.query = &queryResult{
+			trueColor: trueColor,
+			reasons:   append([]string(nil), s.reasons[n:]...),
+		}
+		return trueColor
+	}
+	s.reasons = append(s.reasons, p.query.reasons...)
+	return p.query.trueColor
+}
@@ -0,0 +1,112 @@
+package colorprofile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/xo/terminfo"
+)
+
+func TestStreams(t *testing.T) {
+	var runs, loads int
+	run := fakeRunner(map[string]string{"tmux": tmuxInfoTc})
+	d := Detector{
+		IsTerminal: fakeTerminal,
+		LoadTerminfo: func(term string) (*terminfo.Terminfo, error) {
+			loads++
+			return noTerminfo(term)
+		},
+		RunCommand: func(name string, args ...string) ([]byte, error) {
+			runs++
+			return run(name, args...)
+		},
+	}
+	env := []string{
+		"TERM=tmux-256color",
+		"TMUX=/tmp/tmux-1000/default,1,0",
+		"COLORTERM=truecolor",
+	}
+
+	// stdout is piped, e.g. `mycmd | less`, but stderr is the terminal.
+	stdout, stderr := &fakeFile{fd: 7}, &fakeFile{fd: 42}
+	s := d.Streams(stdout, stderr, env)
+	if s.Stdout.Profile != NoTTY {
+		t.Errorf("expected NoTTY stdout, got %v", s.Stdout.Profile)
+	}
+	if s.Stderr.Profile != TrueColor {
+		t.Errorf("expected TrueColor stderr, got %v", s.Stderr.Profile)
+	}
+	if s.Stdout.Forward != stdout || s.Stderr.Forward != stderr {
+		t.Error("expected the writers to forward to the streams")
+	}
+
+	other := s.Writer(&fakeFile{fd: 42})
+	if other.Profile != TrueColor {
+		t.Errorf("expected TrueColor, got %v", other.Profile)
+	}
+	if runs != 1 {
+		t.Errorf("expected tmux to run once, ran %d times", runs)
+	}
+	if loads != 1 {
+		t.Errorf("expected terminfo to be loaded once, loaded %d times", loads)
+	}
+
+	e := s.Explain(&fakeFile{fd: 42})
+	if !containsReason(e, "running under tmux, which passes TrueColor through") {
+		t.Errorf("expected the tmux reason, got:\n%s", e)
+	}
+
+	_, _ = s.Stderr.WriteString("\x1b[38;2;107;80;255mhi\x1b[m")
+	if stderr.String() != "\x1b[38;2;107;80;255mhi\x1b[m" {
+		t.Errorf("unexpected stderr output %q", stderr.String())
+	}
+	_, _ = s.Stdout.WriteString("\x1b[38;2;107;80;255mhi\x1b[m")
+	if stdout.String() != "hi" {
+		t.Errorf("unexpected stdout output %q", stdout.String())
+	}
+}
+
+func TestStreamsQuery(t *testing.T) {
+	tty := newFakeTTY("\x1bP1+r5463\x1b\\" + da1Reply)
+	d := Detector{
+		IsTerminal:   fakeTerminal,
+		LoadTerminfo: noTerminfo,
+		RunCommand:   fakeRunner(nil),
+		SSH:          SSHQuery,
+		TTY:          tty,
+	}
+	env := []string{
+		"TERM=xterm-256color",
+		"SSH_CONNECTION=10.0.0.2 51234 10.0.0.1 22",
+		"SSH_TTY=/dev/pts/3",
+	}
+
+	s := d.Streams(&fakeFile{fd: 42}, &fakeFile{fd: 42}, env)
+	if s.Stdout.Profile != TrueColor || s.Stderr.Profile != TrueColor {
+		t.Errorf("expected TrueColor, got %v and %v", s.Stdout.Profile, s.Stderr.Profile)
+	}
+	if n := strings.Count(tty.queries.String(), "\x1bP+q"); n != 1 {
+		t.Errorf("expected the terminal to be queried once, got %d queries", n)
+	}
+
+	e := s.Explain(&fakeFile{fd: 42})
+	if !containsReason(e, "terminal reports true color support") {
+		t.Errorf("expected the query reason, got:\n%s", e)
+	}
+}
+
+func TestStreamsEnvironment(t *testing.T) {
+	d := Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo, RunCommand: fakeRunner(nil)}
+	env := []string{"TERM=xterm-256color"}
+	s := d.Streams(new(bytes.Buffer), &fakeFile{fd: 42}, env)
+
+	// The environment is parsed once, changing the slice doesn't matter.
+	env[0] = "TERM=dumb"
+	if p := s.Writer(&fakeFile{fd: 42}).Profile; p != ANSI256 {
+		t.Errorf("expected ANSI256, got %v", p)
+	}
+	if s.Stdout.Profile != NoTTY {
+		t.Errorf("expected NoTTY, got %v", s.Stdout.Profile)
+	}
+}
@@ -0,0 +1,124 @@
+package colorprofile
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// SyncWriter is a [Writer] safe for concurrent use. Writes are serialized,
+// so the SGR sequences of concurrent writes never interleave, and the color
+// profile and terminal limits can be changed atomically while other
+// goroutines write, e.g. by a [Watcher]. Each write uses the profile and
+// limits current when it starts.
+//
+//	w := colorprofile.NewSyncWriter(colorprofile.NewWriter(os.Stdout, os.Environ()))
+//	watcher.OnChange(func(colorprofile.Profile) {
+//		e := watcher.Explanation()
+//		w.Set(e.Profile, e.Limits)
+//	})
+type SyncWriter struct {
+	mu    sync.Mutex
+	w     *Writer
+	out   *Output
+	state atomic.Pointer[syncState]
+}
+
+// syncState is the color profile and terminal limits of a [SyncWriter].
+type syncState struct {
+	profile Profile
+	limits  Limits
+}
+
+// NewSyncWriter returns a writer safe for concurrent use writing through w.
+// w must not be used directly afterwards.
+func NewSyncWriter(w *Writer) *SyncWriter {
+	out, ok := w.Forward.(*Output)
+	if !ok || out == nil {
+		out = &Output{Writer: w.Forward}
+		w.Forward = out
+	}
+	s := &SyncWriter{w: w, out: out}
+	s.state.Store(&syncState{profile: w.Profile, limits: out.Limits})
+	return s
+}
+
+// Profile returns the color profile.
+func (s *SyncWriter) Profile() Profile {
+	return s.state.Load().profile
+}
+
+// Limits returns the colors and attributes the terminal doesn't render. See
+// [Output.Limits].
+func (s *SyncWriter) Limits() Limits {
+	return s.state.Load().limits
+}
+
+// SetProfile changes the color profile. Writes in progress finish with the
+// previous profile.
+func (s *SyncWriter) SetProfile(p Profile) {
+	s.update(func(st *syncState) { st.profile = p })
+}
+
+// SetLimits changes the terminal limits. Writes in progress finish with the
+// previous limits.
+func (s *SyncWriter) SetLimits(l Limits) {
+	s.update(func(st *syncState) { st.limits = l })
+}
+
+// Set changes the color profile and the terminal limits together, e.g. when
+// the output moves to another terminal.
+func (s *SyncWriter) Set(p Profile, l Limits) {
+	s.state.Store(&syncState{profile: p, limits: l})
+}
+
+// update changes the state atomically with f.
+func (s *SyncWriter) update(f func(*syncState)) {
+	for {
+		old := s.state.Load()
+		st := *old
+		f(&st)
+		if s.state.CompareAndSwap(old, &st) {
+			return
+		}
+	}
+}
+
+// Write writes p to the underlying writer with the current color profile
+// and limits.
+func (s *SyncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return s.w.Write(p)
+}
+
+// WriteString writes s to the underlying writer with the current color
+// profile and limits, without copying it into a byte slice first.
+func (s *SyncWriter) WriteString(str string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return s.w.WriteString(str)
+}
+
+// ReadFrom reads text from r until EOF or an error, and writes it to the
+// underlying writer with the color profile and limits current when it
+// starts, see [Writer.ReadFrom]. Other writes wait until it returns.
+func (s *SyncWriter) ReadFrom(r io.Reader) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return s.w.ReadFrom(r)
+}
+
+// load applies the current state to the writer. s.mu must be held.
+func (s *SyncWriter) load() {
+	st := s.state.Load()
+	s.w.Profile, s.out.Limits = st.profile, st.limits
+}
+
+var (
+	_ io.StringWriter = (*SyncWriter)(nil)
+	_ io.ReaderFrom   = (*SyncWriter)(nil)
+)
@@ -0,0 +1,137 @@
+package colorprofile
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSyncWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewSyncWriter(&Writer{Forward: &buf, Profile: TrueColor})
+	if p := w.Profile(); p != TrueColor {
+		t.Errorf("expected TrueColor, got %v", p)
+	}
+
+	_, _ = w.WriteString("\x1b[38;2;107;80;255mhi\x1b[m")
+	w.SetProfile(ANSI256)
+	if p := w.Profile(); p != ANSI256 {
+		t.Errorf("expected ANSI256, got %v", p)
+	}
+	_, _ = w.WriteString("\x1b[38;2;107;80;255mhi\x1b[m")
+
+	if expected := "\x1b[38;2;107;80;255mhi\x1b[m\x1b[38;5;63mhi\x1b[m"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestSyncWriterConcurrency(t *testing.T) {
+	const (
+		writers = 8
+		writes  = 200
+		line    = "\x1b[1;38;2;107;80;255mhello \x1b[48;5;196mworld\x1b[m\n"
+	)
+
+	// Each line must be rendered whole with one of the profiles.
+	profiles := []Profile{TrueColor, ANSI256, ANSI, ASCII, NoTTY}
+	valid := make(map[string]bool)
+	for _, p := range profiles {
+		var b bytes.Buffer
+		_, _ = (&Writer{Forward: &b, Profile: p}).WriteString(line)
+		valid[b.String()] = true
+	}
+
+	var buf bytes.Buffer
+	w := NewSyncWriter(&Writer{Forward: &buf, Profile: TrueColor})
+
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range writes {
+				if _, err := w.WriteString(line); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range writers * writes {
+			w.SetProfile(profiles[i%len(profiles)])
+			_ = w.Profile()
+		}
+	}()
+	wg.Wait()
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	lines = lines[:len(lines)-1]
+	if len(lines) != writers*writes {
+		t.Fatalf("expected %d lines, got %d", writers*writes, len(lines))
+	}
+	for _, l := range lines {
+		if !valid[l] {
+			t.Fatalf("unexpected line %q", l)
+		}
+	}
+}
+
+func TestSyncWriterLimits(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewSyncWriter(&Writer{Forward: &buf, Profile: ANSI})
+	if l := w.Limits(); l != (Limits{}) {
+		t.Errorf("expected no limits, got %v", l)
+	}
+
+	const line = "\x1b[3;101mhi\x1b[m"
+	_, _ = w.WriteString(line)
+	w.SetLimits(linuxLimits)
+	if l := w.Limits(); l != linuxLimits {
+		t.Errorf("expected %v, got %v", linuxLimits, l)
+	}
+	_, _ = w.WriteString(line)
+
+	// Moving to another terminal changes both.
+	w.Set(ANSI256, kmsconLimits)
+	if p, l := w.Profile(), w.Limits(); p != ANSI256 || l != kmsconLimits {
+		t.Errorf("expected ANSI256 with %v, got %v with %v", kmsconLimits, p, l)
+	}
+	w.SetProfile(ANSI)
+	if l := w.Limits(); l != kmsconLimits {
+		t.Errorf("expected SetProfile to keep %v, got %v", kmsconLimits, l)
+	}
+
+	if expected := "\x1b[3;101mhi\x1b[m\x1b[41mhi\x1b[m"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// The limits of a writer from NewWriter are kept.
+	d := Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo}
+	if l := NewSyncWriter(d.NewWriter(&fakeFile{fd: 42}, []string{"TERM=linux"})).Limits(); l != linuxLimits {
+		t.Errorf("expected %v, got %v", linuxLimits, l)
+	}
+}
+
+func TestSyncWriterReadFrom(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewSyncWriter(&Writer{Forward: &buf, Profile: ANSI256})
+
+	// Sequences split between reads are converted whole.
+	const input = "\x1b[38;2;107;80;255mhi\x1b[m"
+	n, err := io.Copy(w, iotest.OneByteReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != int64(len(input)) {
+		t.Errorf("expected %d bytes read, got %d", len(input), n)
+	}
+	if expected := "\x1b[38;5;63mhi\x1b[m"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
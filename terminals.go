@@ -0,0 +1,149 @@
+package colorprofile
+
+import "strings"
+
+// Terminal describes the color support of a terminal. Terminals are matched
+// against the TERM name and optionally the TERM_PROGRAM and
+// TERM_PROGRAM_VERSION environment variables. All the non-empty criteria
+// have to match.
+//
+// The profile of the first matching terminal is used as is, it isn't
+// upgraded by the TERM name, e.g. a "256color" suffix, or by the terminfo
+// entry, so entries can downgrade terminals too. Only COLORTERM, unless
+// ignored, and Windows Terminal upgrade it.
+type Terminal struct {
+	// Term is the exact TERM name to match, e.g. "xterm-kitty".
+	Term string
+	// Family is the TERM family to match. A family matches its own name and
+	// its variants separated by "-" or ".", e.g. "foot" matches "foot",
+	// "foot-extra", and "foot-direct", but not "footer".
+	Family string
+	// Program is the TERM_PROGRAM value to match, e.g. "iTerm.app".
+	Program string
+	// Version is the minimum TERM_PROGRAM_VERSION to match. It requires
+	// Program to be set.
+	Version string
+	// Profile is the color profile the terminal supports.
+	Profile Profile
+	// IgnoreColorTerm ignores COLORTERM for this terminal. Multiplexers
+	// inherit COLORTERM from the terminal they run in, but don't necessarily
+	// pass true colors through.
+	IgnoreColorTerm bool
+	// Limits are the colors and attributes the terminal doesn't render
+	// despite its profile.
+	Limits Limits
+}
+
+// terminals is the built-in terminal database. Entries are matched in order
+// and the first match wins, so more specific entries come first.
+var terminals = []Terminal{
+	// Multiplexers. tmux-direct tells the terminal tmux runs in supports
+	// true colors.
+	{Term: "tmux-direct", Profile: TrueColor, IgnoreColorTerm: true},
+	{Family: "tmux", Profile: ANSI256, IgnoreColorTerm: true},
+	{Family: "screen", Profile: ANSI256, IgnoreColorTerm: true},
+
+	// Terminals using an xterm TERM name
+	{Term: "xterm-ghostty", Profile: TrueColor},
+	{Term: "xterm-kitty", Profile: TrueColor},
+
+	// True color terminals
+	{Family: "alacritty", Profile: TrueColor},
+	{Family: "contour", Profile: TrueColor},
+	{Family: "foot", Profile: TrueColor},
+	{Family: "ghostty", Profile: TrueColor},
+	{Family: "kitty", Profile: TrueColor},
+	{Family: "mintty", Profile: TrueColor},
+	{Family: "ms-terminal", Profile: TrueColor}, // Windows Terminal
+	{Family: "rio", Profile: TrueColor},
+	{Family: "st", Profile: TrueColor},
+	{Family: "stterm", Profile: TrueColor}, // st on Debian
+	{Family: "wezterm", Profile: TrueColor},
+
+	// Terminals identified by TERM_PROGRAM. These come after the TERM based
+	// entries since terminals launched from another terminal inherit its
+	// TERM_PROGRAM.
+	{Program: "Apple_Terminal", Version: "460", Profile: TrueColor}, // macOS 26
+	{Program: "Apple_Terminal", Profile: ANSI256},
+	{Program: "ghostty", Profile: TrueColor},
+	{Program: "Hyper", Profile: TrueColor},
+	{Program: "iTerm.app", Version: "3", Profile: TrueColor},
+	{Program: "iTerm.app", Profile: ANSI256},
+	// iTerm2 forwards LC_TERMINAL=iTerm2 over SSH. See [SSHLCTerminal].
+	{Program: "iTerm2", Version: "3", Profile: TrueColor},
+	{Program: "iTerm2", Profile: ANSI256},
+	{Program: "mintty", Profile: TrueColor},
+	{Program: "vscode", Profile: TrueColor},
+	{Program: "WezTerm", Profile: TrueColor},
+
+	// Consoles. fbterm advertises 256 colors in terminfo, but with private
+	// sequences, so only the ANSI colors are usable. kmscon uses
+	// TERM=xterm-256color unless configured otherwise. They ignore the
+	// COLORTERM inherited through su, sudo, or SSH.
+	{Family: "linux", Profile: ANSI, IgnoreColorTerm: true, Limits: linuxLimits},
+	{Family: "fbterm", Profile: ANSI, IgnoreColorTerm: true, Limits: linuxLimits},
+	{Family: "kmscon", Profile: ANSI256, IgnoreColorTerm: true, Limits: kmsconLimits},
+}
+
+// String returns a description of the terminal criteria.
+func (t Terminal) String() string {
+	var parts []string
+	if len(t.Term) > 0 {
+		parts = append(parts, "TERM="+t.Term)
+	}
+	if len(t.Family) > 0 {
+		parts = append(parts, "TERM family "+t.Family)
+	}
+	if len(t.Program) > 0 {
+		program := "TERM_PROGRAM=" + t.Program
+		if len(t.Version) > 0 {
+			program += " version " + t.Version + " or later"
+		}
+		parts = append(parts, program)
+	}
+	return strings.Join(parts, " and ")
+}
+
+// match reports whether the terminal matches the given environment.
+func (t Terminal) match(env environ) bool {
+	if len(t.Term) == 0 && len(t.Family) == 0 && len(t.Program) == 0 {
+		return false
+	}
+
+	term := env.get("TERM")
+	if len(t.Term) > 0 && term != t.Term {
+		return false
+	}
+	if len(t.Family) > 0 && !inFamily(term, t.Family) {
+		return false
+	}
+	if len(t.Program) > 0 {
+		if env.get("TERM_PROGRAM") != t.Program {
+			return false
+		}
+		if len(t.Version) > 0 && !versionAtLeast(env.get("TERM_PROGRAM_VERSION"), t.Version) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inFamily reports whether the TERM name belongs to the given family.
+func inFamily(term, family string) bool {
+	if len(term) < len(family) || term[:len(family)] != family {
+		return false
+	}
+	return len(term) == len(family) || term[len(family)] == '-' || term[len(family)] == '.'
+}
+
+// lookupTerminal returns the first terminal in the terminal database db that
+// matches the environment.
+func lookupTerminal(db []Terminal, env environ) (Terminal, bool) {
+	for _, t := range db {
+		if t.match(env) {
+			return t, true
+		}
+	}
+	return Terminal{}, false
+}
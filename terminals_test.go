@@ -0,0 +1,272 @@
+package colorprofile
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xo/terminfo"
+)
+
+func TestTerminalDatabase(t *testing.T) {
+	f, err := os.Open("testdata/terms.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var n int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			t.Fatalf("invalid line %q", line)
+		}
+
+		n++
+		term, expected := fields[0], fields[1]
+		if p := Env([]string{"TERM=" + term}); p.String() != expected {
+			t.Errorf("TERM=%s: expected %s, got %v", term, expected, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n < 100 {
+		t.Errorf("expected hundreds of TERM values, got %d", n)
+	}
+}
+
+func TestTerminalMatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		terminal Terminal
+		environ  []string
+		expected bool
+	}{
+		{
+			name:     "empty terminal",
+			terminal: Terminal{Profile: TrueColor},
+			environ:  []string{"TERM=xterm"},
+			expected: false,
+		},
+		{
+			name:     "exact term",
+			terminal: Terminal{Term: "xterm-kitty"},
+			environ:  []string{"TERM=xterm-kitty"},
+			expected: true,
+		},
+		{
+			name:     "exact term mismatch",
+			terminal: Terminal{Term: "xterm"},
+			environ:  []string{"TERM=xterm-256color"},
+			expected: false,
+		},
+		{
+			name:     "family",
+			terminal: Terminal{Family: "st"},
+			environ:  []string{"TERM=st-256color"},
+			expected: true,
+		},
+		{
+			name:     "family name",
+			terminal: Terminal{Family: "st"},
+			environ:  []string{"TERM=st"},
+			expected: true,
+		},
+		{
+			name:     "family dot variant",
+			terminal: Terminal{Family: "screen"},
+			environ:  []string{"TERM=screen.xterm-256color"},
+			expected: true,
+		},
+		{
+			name:     "family prefix only",
+			terminal: Terminal{Family: "st"},
+			environ:  []string{"TERM=stv52"},
+			expected: false,
+		},
+		{
+			name:     "family substring",
+			terminal: Terminal{Family: "st"},
+			environ:  []string{"TERM=vt100-stuff"},
+			expected: false,
+		},
+		{
+			name:     "program",
+			terminal: Terminal{Program: "MyTerm"},
+			environ:  []string{"TERM=xterm-256color", "TERM_PROGRAM=MyTerm"},
+			expected: true,
+		},
+		{
+			name:     "program and family",
+			terminal: Terminal{Family: "xterm", Program: "MyTerm"},
+			environ:  []string{"TERM=screen", "TERM_PROGRAM=MyTerm"},
+			expected: false,
+		},
+		{
+			name:     "program version",
+			terminal: Terminal{Program: "MyTerm", Version: "2.1"},
+			environ:  []string{"TERM_PROGRAM=MyTerm", "TERM_PROGRAM_VERSION=2.10.0"},
+			expected: true,
+		},
+		{
+			name:     "program version too old",
+			terminal: Terminal{Program: "MyTerm", Version: "2.1"},
+			environ:  []string{"TERM_PROGRAM=MyTerm", "TERM_PROGRAM_VERSION=2.0.9"},
+			expected: false,
+		},
+		{
+			name:     "program version missing",
+			terminal: Terminal{Program: "MyTerm", Version: "2.1"},
+			environ:  []string{"TERM_PROGRAM=MyTerm"},
+			expected: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.terminal.match(newEnviron(tc.environ)); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDetectorTerminals(t *testing.T) {
+	d := Detector{
+		Terminals: []Terminal{
+			{Term: "xterm-256color", Program: "MyTerm", Profile: TrueColor},
+			{Family: "st", Profile: ANSI256},
+			{Family: "myterm", Profile: ANSI256, IgnoreColorTerm: true},
+			{Term: "foot-direct", Profile: ANSI256},
+			{Family: "oldterm", Profile: ANSI},
+			{Term: "eterm-color", Profile: ANSI},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		environ  []string
+		expected Profile
+	}{
+		{
+			name:     "user terminal",
+			environ:  []string{"TERM=xterm-256color", "TERM_PROGRAM=MyTerm"},
+			expected: TrueColor,
+		},
+		{
+			name:     "user terminal not matching",
+			environ:  []string{"TERM=xterm-256color", "TERM_PROGRAM=OtherTerm"},
+			expected: ANSI256,
+		},
+		{
+			name:     "override built-in terminal",
+			environ:  []string{"TERM=st-256color"},
+			expected: ANSI256,
+		},
+		{
+			name:     "ignore COLORTERM",
+			environ:  []string{"TERM=myterm", "COLORTERM=truecolor"},
+			expected: ANSI256,
+		},
+		{
+			name:     "downgrade direct color terminal",
+			environ:  []string{"TERM=foot-direct"},
+			expected: ANSI256,
+		},
+		{
+			name:     "downgrade 256 color terminal",
+			environ:  []string{"TERM=oldterm-256color"},
+			expected: ANSI,
+		},
+		{
+			name:     "override editor terminal",
+			environ:  []string{"TERM=eterm-color", "INSIDE_EMACS=29.1,vterm"},
+			expected: ANSI,
+		},
+		{
+			name:     "editor terminal before built-in terminal",
+			environ:  []string{"TERM=xterm-kitty", "VIM_TERMINAL=900"},
+			expected: ANSI256,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if p := d.Env(tc.environ); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+
+	// The terminfo entry doesn't upgrade known terminals either.
+	d.IsTerminal = fakeTerminal
+	d.LoadTerminfo = func(string) (*terminfo.Terminfo, error) {
+		return newTerminfo(1<<24, []string{"RGB"}, nil, nil), nil
+	}
+	if p := d.Detect(&fakeFile{fd: 42}, []string{"TERM=foot-direct"}); p != ANSI256 {
+		t.Errorf("expected ANSI256, got %v", p)
+	}
+}
+
+func TestDetectorLimits(t *testing.T) {
+	ti, err := TerminfoFS(os.DirFS("testdata/terminfo"))("xterm-256color")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d := Detector{
+		IsTerminal: fakeTerminal,
+		LoadTerminfo: func(string) (*terminfo.Terminfo, error) {
+			// All the entries advertise 256 colors, like fbterm.
+			return ti, nil
+		},
+	}
+
+	cases := []struct {
+		name     string
+		environ  []string
+		expected Profile
+		limits   Limits
+	}{
+		{"linux", []string{"TERM=linux"}, ANSI, linuxLimits},
+		{"linux-16color", []string{"TERM=linux-16color"}, ANSI, linuxLimits},
+		{"fbterm", []string{"TERM=fbterm"}, ANSI, linuxLimits},
+		{"kmscon", []string{"TERM=kmscon"}, ANSI256, kmsconLimits},
+		{"xterm", []string{"TERM=xterm"}, ANSI256, Limits{}},
+		{"linux, NO_COLOR", []string{"TERM=linux", "NO_COLOR=1"}, ASCII, linuxLimits},
+		{"linux, COLORTERM", []string{"TERM=linux", "COLORTERM=truecolor"}, ANSI, linuxLimits},
+		{"fbterm, COLORTERM", []string{"TERM=fbterm", "COLORTERM=truecolor"}, ANSI, linuxLimits},
+		{"kmscon, COLORTERM", []string{"TERM=kmscon", "COLORTERM=truecolor"}, ANSI256, kmsconLimits},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := d.Explain(&fakeFile{fd: 42}, tc.environ)
+			if e.Profile != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, e.Profile)
+			}
+			if e.Limits != tc.limits {
+				t.Errorf("expected limits %v, got %v", tc.limits, e.Limits)
+			}
+			w := d.NewWriter(&fakeFile{fd: 42}, tc.environ)
+			o, _ := w.Forward.(*Output)
+			if l := o.limits(); l != tc.limits {
+				t.Errorf("expected writer limits %v, got %v", tc.limits, l)
+			}
+		})
+	}
+
+	if s := linuxLimits.String(); s != "no italic, conceal, strikethrough, overline, underline color, no bright backgrounds" {
+		t.Errorf("unexpected limits description %q", s)
+	}
+	if s := (Limits{}).String(); s != "no limits" {
+		t.Errorf("unexpected limits description %q", s)
+	}
+}
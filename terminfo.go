@@ -0,0 +1,223 @@
+package colorprofile
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xo/terminfo"
+)
+
+// TerminfoCaps are the color capabilities of a terminfo entry.
+type TerminfoCaps struct {
+	// Colors is the colors capability, the number of colors the terminal
+	// supports, e.g. 8, 16, 88, 256, or 16777216 for direct color
+	// terminals. It's zero if the terminal doesn't support colors.
+	Colors int
+	// Tc is the Tc extended capability tmux uses to tell the terminal
+	// supports true colors.
+	Tc bool
+	// RGB is the RGB extended capability ncurses uses to tell the terminal
+	// supports direct colors. It can be a boolean, a number, or a string.
+	RGB bool
+	// SetRGBForeground and SetRGBBackground are the setrgbf and setrgbb
+	// extended capabilities, the sequences setting true colors.
+	SetRGBForeground, SetRGBBackground string
+	// CanChange is the ccc capability, telling the terminal can redefine
+	// its palette colors.
+	CanChange bool
+	// InitColor is the initc capability, the sequence redefining a palette
+	// color.
+	InitColor string
+}
+
+// ParseTerminfo returns the color capabilities of a terminfo entry.
+func ParseTerminfo(ti *terminfo.Terminfo) TerminfoCaps {
+	caps := TerminfoCaps{
+		Colors:    ti.Nums[terminfo.MaxColors],
+		CanChange: ti.Bools[terminfo.CanChange],
+		InitColor: string(ti.Strings[terminfo.InitializeColor]),
+	}
+
+	extbools := ti.ExtBoolCapsShort()
+	extnums := ti.ExtNumCapsShort()
+	extstrings := ti.ExtStringCapsShort()
+	caps.Tc = extbools["Tc"]
+	_, rgbnum := extnums["RGB"]
+	_, rgbstr := extstrings["RGB"]
+	caps.RGB = extbools["RGB"] || rgbnum || rgbstr
+	caps.SetRGBForeground = string(extstrings["setrgbf"])
+	caps.SetRGBBackground = string(extstrings["setrgbb"])
+
+	return caps
+}
+
+// LoadTerminfoCaps loads the terminfo entry of term from the system terminfo
+// database and returns its color capabilities.
+func LoadTerminfoCaps(term string) (TerminfoCaps, error) {
+	if !validTermName(term) {
+		return TerminfoCaps{}, terminfo.ErrFileNotFound
+	}
+	ti, err := terminfo.Load(term)
+	if err != nil {
+		return TerminfoCaps{}, err //nolint:wrapcheck
+	}
+	return ParseTerminfo(ti), nil
+}
+
+// Profile returns the color profile the capabilities support:
+//   - Tc, RGB, setrgbf, or 16777216 colors support TrueColor.
+//   - 256 colors support ANSI256.
+//   - 16 and 88 colors support ANSI. 88 color terminals don't have the 256
+//     color palette, so only their first 16 colors are used.
+//   - 8 colors and fewer support ASCII, since ANSI includes the 8 bright
+//     colors these terminals lack.
+//
+// The ccc and initc capabilities don't change the profile since redefining
+// the palette doesn't give more colors at once.
+//
+// Many terminals render the bright colors despite entries claiming 8 colors,
+// e.g. xterm, so the detection only uses terminfo to upgrade the profile.
+func (c TerminfoCaps) Profile() Profile {
+	switch {
+	case c.Tc, c.RGB, len(c.SetRGBForeground) > 0, c.Colors >= 1<<24:
+		return TrueColor
+	case c.Colors >= 256: //nolint:mnd
+		return ANSI256
+	case c.Colors >= 16: //nolint:mnd
+		return ANSI
+	default:
+		return ASCII
+	}
+}
+
+// String returns the capabilities in the infocmp format, e.g. "colors#256,
+// Tc".
+func (c TerminfoCaps) String() string {
+	var caps []string
+	if c.Colors > 0 {
+		caps = append(caps, "colors#"+strconv.Itoa(c.Colors))
+	}
+	if c.Tc {
+		caps = append(caps, "Tc")
+	}
+	if c.RGB {
+		caps = append(caps, "RGB")
+	}
+	if len(c.SetRGBForeground) > 0 {
+		caps = append(caps, "setrgbf")
+	}
+	if len(c.SetRGBBackground) > 0 {
+		caps = append(caps, "setrgbb")
+	}
+	if c.CanChange {
+		caps = append(caps, "ccc")
+	}
+	if len(c.InitColor) > 0 {
+		caps = append(caps, "initc")
+	}
+	if len(caps) == 0 {
+		return "no color capabilities"
+	}
+	return strings.Join(caps, ", ")
+}
+
+// Terminfo returns the color profile based on the terminal's terminfo
+// database. This relies on the colors, Tc, RGB, and setrgbf capabilities.
+// See [TerminfoCaps.Profile].
+// If term is empty or "dumb", it returns NoTTY. If term isn't installed, it
+// falls back to a built-in database of common entries, and returns ANSI if
+// term isn't there either.
+//
+// Terminfo returns at least ANSI for terminals that aren't dumb, even if
+// their entries claim fewer than 16 colors or none at all, where
+// [TerminfoCaps.Profile] and the explanations of [Detector.Explain] say
+// ASCII. It keeps the floor of earlier versions, which callers rely on,
+// and matches what most of these terminals render, e.g. the xterm entry
+// claims 8 colors, yet xterm renders the 16 ANSI colors. Detection doesn't
+// downgrade them either. Use [LoadTerminfoCaps] for the exact capabilities.
+//
+// The terminfo database is searched using the process environment. Use a
+// [Detector] to search it using another environment.
+func Terminfo(term string) (p Profile) {
+	if len(term) == 0 || term == "dumb" {
+		return NoTTY
+	}
+
+	caps, err := LoadTerminfoCaps(term)
+	if err != nil {
+		var ok bool
+		if caps, ok = builtinTerminfo[term]; !ok {
+			return ANSI
+		}
+	}
+
+	return terminfoProfile(caps)
+}
+
+// terminfoProfile returns the color profile [Terminfo] returns for the
+// capabilities, which is at least ANSI. See [Terminfo] for why it differs
+// from [TerminfoCaps.Profile].
+func terminfoProfile(caps TerminfoCaps) Profile {
+	return max(caps.Profile(), ANSI)
+}
+
+// systemTerminfoDirs are the terminfo directories searched last.
+var systemTerminfoDirs = []string{"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo"}
+
+// terminfoDirs returns the terminfo directories to search in order, as
+// described in terminfo(5): TERMINFO, $HOME/.terminfo, TERMINFO_DIRS, where
+// an empty entry stands for the system directories, and the system
+// directories.
+func terminfoDirs(env environ) []string {
+	var dirs []string
+	if dir := env.get("TERMINFO"); len(dir) > 0 {
+		dirs = append(dirs, dir)
+	}
+	if home := env.get("HOME"); len(home) > 0 {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	if list := env.get("TERMINFO_DIRS"); len(list) > 0 {
+		for _, dir := range filepath.SplitList(list) {
+			if len(dir) == 0 {
+				dirs = append(dirs, systemTerminfoDirs...)
+				continue
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	return append(dirs, systemTerminfoDirs...)
+}
+
+// validTermName reports whether term can name a terminfo entry, so that
+// looking it up can't escape the terminfo directories.
+func validTermName(term string) bool {
+	return len(term) > 0 && !strings.ContainsAny(term, "/\\") && !strings.Contains(term, "..")
+}
+
+// TerminfoFS returns a terminfo loader reading the entries from fsys, which
+// is laid out like a terminfo directory, e.g. os.DirFS("/usr/share/terminfo").
+// Entries are looked up in both the "x/xterm" and "78/xterm" layouts. Use it
+// with [Detector.LoadTerminfo].
+func TerminfoFS(fsys fs.FS) func(term string) (*terminfo.Terminfo, error) {
+	return func(term string) (*terminfo.Terminfo, error) {
+		if !validTermName(term) {
+			return nil, terminfo.ErrFileNotFound
+		}
+
+		for _, dir := range []string{term[:1], strconv.FormatInt(int64(term[0]), 16)} {
+			b, err := fs.ReadFile(fsys, dir+"/"+term)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+			return terminfo.Decode(b) //nolint:wrapcheck
+		}
+
+		return nil, terminfo.ErrFileNotFound
+	}
+}
@@ -0,0 +1,58 @@
+package colorprofile
+
+// direct is the number of colors of direct color terminals.
+const direct = 1 << 24
+
+// builtinTerminfo is the fallback terminfo database used when an entry isn't
+// installed, e.g. in minimal containers. It only has the color capabilities
+// of common entries, taken from the ncurses 6.5 database, without initc.
+var builtinTerminfo = map[string]TerminfoCaps{
+	"alacritty":             {Colors: 256, CanChange: true},
+	"alacritty-direct":      {Colors: direct, RGB: true},
+	"ansi":                  {Colors: 8},
+	"contour":               {Colors: 256, CanChange: true},
+	"cygwin":                {Colors: 8},
+	"Eterm":                 {Colors: 8},
+	"eterm-color":           {Colors: 8},
+	"foot":                  {Colors: 256, CanChange: true},
+	"foot-direct":           {Colors: direct, RGB: true},
+	"gnome":                 {Colors: 8},
+	"gnome-256color":        {Colors: 256, CanChange: true},
+	"iterm2":                {Colors: 256},
+	"kitty":                 {Colors: 256, CanChange: true},
+	"kitty-direct":          {Colors: direct, RGB: true},
+	"konsole":               {Colors: 8},
+	"konsole-256color":      {Colors: 256},
+	"konsole-direct":        {Colors: direct, RGB: true},
+	"linux":                 {Colors: 8, CanChange: true},
+	"mintty":                {Colors: 256, CanChange: true},
+	"mlterm":                {Colors: 8},
+	"ms-terminal":           {Colors: 256, CanChange: true},
+	"putty":                 {Colors: 8, CanChange: true},
+	"putty-256color":        {Colors: 256},
+	"rio":                   {Colors: 256, CanChange: true},
+	"rxvt":                  {Colors: 8},
+	"rxvt-256color":         {Colors: 256, CanChange: true},
+	"rxvt-unicode":          {Colors: 88, CanChange: true},
+	"rxvt-unicode-256color": {Colors: 256, CanChange: true},
+	"screen":                {Colors: 8},
+	"screen-256color":       {Colors: 256},
+	"screen.xterm-256color": {Colors: 256},
+	"st":                    {Colors: 8},
+	"st-256color":           {Colors: 256, CanChange: true},
+	"st-direct":             {Colors: direct, RGB: true},
+	"tmux":                  {Colors: 8},
+	"tmux-256color":         {Colors: 256},
+	"tmux-direct":           {Colors: direct, RGB: true},
+	"vt100":                 {},
+	"vt220":                 {},
+	"vte":                   {Colors: 8},
+	"vte-256color":          {Colors: 256, CanChange: true},
+	"wezterm":               {Colors: 256, CanChange: true},
+	"xterm":                 {Colors: 8},
+	"xterm-16color":         {Colors: 16, CanChange: true},
+	"xterm-256color":        {Colors: 256, CanChange: true},
+	"xterm-88color":         {Colors: 88, CanChange: true},
+	"xterm-color":           {Colors: 8},
+	"xterm-direct":          {Colors: direct, RGB: true},
+}
@@ -0,0 +1,305 @@
+package colorprofile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xo/terminfo"
+)
+
+// newTerminfo returns a terminfo entry with the given colors and extended
+// capabilities.
+func newTerminfo(colors int, bools []string, nums map[string]int, strs map[string]string) *terminfo.Terminfo {
+	ti := &terminfo.Terminfo{
+		Bools:          map[int]bool{},
+		Nums:           map[int]int{},
+		Strings:        map[int][]byte{},
+		ExtBools:       map[int]bool{},
+		ExtBoolNames:   map[int][]byte{},
+		ExtNums:        map[int]int{},
+		ExtNumNames:    map[int][]byte{},
+		ExtStrings:     map[int][]byte{},
+		ExtStringNames: map[int][]byte{},
+	}
+	if colors > 0 {
+		ti.Nums[terminfo.MaxColors] = colors
+	}
+	for i, name := range bools {
+		ti.ExtBools[i] = true
+		ti.ExtBoolNames[i] = []byte(name)
+	}
+	i := 0
+	for name, v := range nums {
+		ti.ExtNums[i] = v
+		ti.ExtNumNames[i] = []byte(name)
+		i++
+	}
+	i = 0
+	for name, v := range strs {
+		ti.ExtStrings[i] = []byte(v)
+		ti.ExtStringNames[i] = []byte(name)
+		i++
+	}
+	return ti
+}
+
+func TestTerminfoCaps(t *testing.T) {
+	cases := []struct {
+		name     string
+		ti       *terminfo.Terminfo
+		expected Profile
+		caps     string
+	}{
+		{"no colors", newTerminfo(0, nil, nil, nil), ASCII, "no color capabilities"},
+		{"monochrome", newTerminfo(2, nil, nil, nil), ASCII, "colors#2"},
+		{"8 colors", newTerminfo(8, nil, nil, nil), ASCII, "colors#8"},
+		{"16 colors", newTerminfo(16, nil, nil, nil), ANSI, "colors#16"},
+		{"88 colors", newTerminfo(88, nil, nil, nil), ANSI, "colors#88"},
+		{"256 colors", newTerminfo(256, nil, nil, nil), ANSI256, "colors#256"},
+		{"direct colors", newTerminfo(1<<24, nil, nil, nil), TrueColor, "colors#16777216"},
+		{"Tc", newTerminfo(256, []string{"Tc"}, nil, nil), TrueColor, "colors#256, Tc"},
+		{"RGB boolean", newTerminfo(256, []string{"RGB"}, nil, nil), TrueColor, "colors#256, RGB"},
+		{"RGB number", newTerminfo(256, nil, map[string]int{"RGB": 8}, nil), TrueColor, "colors#256, RGB"},
+		{"RGB string", newTerminfo(256, nil, nil, map[string]string{"RGB": "8/8/8"}), TrueColor, "colors#256, RGB"},
+		{
+			"setrgbf",
+			newTerminfo(256, nil, nil, map[string]string{
+				"setrgbf": "\x1b[38:2:%p1%d:%p2%d:%p3%dm",
+				"setrgbb": "\x1b[48:2:%p1%d:%p2%d:%p3%dm",
+			}),
+			TrueColor,
+			"colors#256, setrgbf, setrgbb",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			caps := ParseTerminfo(tc.ti)
+			if p := caps.Profile(); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+			if s := caps.String(); s != tc.caps {
+				t.Errorf("expected %q, got %q", tc.caps, s)
+			}
+		})
+	}
+
+	ti := newTerminfo(88, nil, nil, nil)
+	ti.Bools[terminfo.CanChange] = true
+	ti.Strings[terminfo.InitializeColor] = []byte("\x1b]4;%p1%d;rgb:%p2%{255}%*%{1000}%/%2.2X/%p3%{255}%*%{1000}%/%2.2X/%p4%{255}%*%{1000}%/%2.2X\x1b\\")
+	caps := ParseTerminfo(ti)
+	if !caps.CanChange || len(caps.InitColor) == 0 || caps.Profile() != ANSI {
+		t.Errorf("expected an 88 color palette that can change, got %s supporting %v", caps, caps.Profile())
+	}
+}
+
+func TestTerminfoProfile(t *testing.T) {
+	cases := []struct {
+		name     string
+		caps     TerminfoCaps
+		expected Profile
+	}{
+		{"no colors", TerminfoCaps{}, ANSI},
+		{"8 colors", TerminfoCaps{Colors: 8}, ANSI},
+		{"16 colors", TerminfoCaps{Colors: 16}, ANSI},
+		{"88 colors", TerminfoCaps{Colors: 88}, ANSI},
+		{"256 colors", TerminfoCaps{Colors: 256}, ANSI256},
+		{"RGB", TerminfoCaps{Colors: 256, RGB: true}, TrueColor},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if p := terminfoProfile(tc.caps); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+
+	// Entries without color capabilities, and unknown ones, are ANSI like
+	// they used to be.
+	for _, term := range []string{"vt100", "colorprofile-unknown-term"} {
+		if p := Terminfo(term); p != ANSI {
+			t.Errorf("%s: expected ANSI, got %v", term, p)
+		}
+	}
+}
+
+func TestTerminfoFS(t *testing.T) {
+	load := TerminfoFS(os.DirFS("testdata/terminfo"))
+
+	cases := []struct {
+		term     string
+		expected Profile
+	}{
+		{"xterm-256color", ANSI256},
+		{"xterm-direct", TrueColor},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.term, func(t *testing.T) {
+			ti, err := load(tc.term)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p := ParseTerminfo(ti).Profile(); p != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, p)
+			}
+		})
+	}
+
+	for _, term := range []string{"", "missing", "../x/xterm-256color"} {
+		if _, err := load(term); err == nil {
+			t.Errorf("%q: expected an error", term)
+		}
+	}
+}
+
+func TestDetectorTerminfo(t *testing.T) {
+	d := &Detector{
+		IsTerminal:   fakeTerminal,
+		LoadTerminfo: TerminfoFS(os.DirFS("testdata/terminfo")),
+	}
+	e := d.Explain(&fakeFile{fd: 42}, []string{"TERM=xterm-direct"})
+	if e.Profile != TrueColor {
+		t.Errorf("expected TrueColor, got %v", e.Profile)
+	}
+
+	// The terminfo entry doesn't downgrade the environment.
+	d.LoadTerminfo = func(string) (*terminfo.Terminfo, error) {
+		return newTerminfo(0, nil, nil, nil), nil
+	}
+	if p := d.Detect(&fakeFile{fd: 42}, []string{"TERM=xterm"}); p != ANSI {
+		t.Errorf("expected ANSI, got %v", p)
+	}
+
+	d.LoadTerminfo = func(string) (*terminfo.Terminfo, error) {
+		return newTerminfo(256, nil, nil, nil), nil
+	}
+	e = d.Explain(&fakeFile{fd: 42}, []string{"TERM=myterm"})
+	if e.Profile != ANSI256 {
+		t.Errorf("expected ANSI256, got %v", e.Profile)
+	}
+	if want := "terminfo entry for TERM=myterm has colors#256, supporting ANSI256"; !containsReason(e, want) {
+		t.Errorf("expected explanation to mention %q, got:\n%s", want, e)
+	}
+}
+
+func containsReason(e Explanation, reason string) bool {
+	for _, r := range e.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTerminfoDirs(t *testing.T) {
+	system := strings.Join(systemTerminfoDirs, ":")
+	cases := []struct {
+		name     string
+		environ  []string
+		expected string
+	}{
+		{"empty", nil, system},
+		{"TERMINFO", []string{"TERMINFO=/opt/terminfo"}, "/opt/terminfo:" + system},
+		{"HOME", []string{"HOME=/home/u"}, filepath.Join("/home/u", ".terminfo") + ":" + system},
+		{
+			"TERMINFO_DIRS",
+			[]string{"TERMINFO_DIRS=/a" + string(filepath.ListSeparator) + "/b"},
+			"/a:/b:" + system,
+		},
+		{
+			"TERMINFO_DIRS with system directories",
+			[]string{"TERMINFO_DIRS=/a" + string(filepath.ListSeparator)},
+			"/a:" + system + ":" + system,
+		},
+		{
+			"all",
+			[]string{"TERMINFO=/opt/terminfo", "HOME=/home/u", "TERMINFO_DIRS=/a"},
+			"/opt/terminfo:" + filepath.Join("/home/u", ".terminfo") + ":/a:" + system,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if dirs := strings.Join(terminfoDirs(newEnviron(tc.environ)), ":"); dirs != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, dirs)
+			}
+		})
+	}
+}
+
+func TestDetectorTerminfoEnv(t *testing.T) {
+	// Don't find the entries installed on the system.
+	system := systemTerminfoDirs
+	systemTerminfoDirs = nil
+	t.Cleanup(func() { systemTerminfoDirs = system })
+
+	home := t.TempDir()
+	b, err := os.ReadFile(filepath.Join("testdata", "terminfo", "m", "myterm"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".terminfo", "6d"), 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".terminfo", "6d", "myterm"), b, 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir := filepath.Join("testdata", "terminfo")
+	cases := []struct {
+		name     string
+		environ  []string
+		expected Profile
+		reason   string
+	}{
+		{
+			name:     "not installed",
+			environ:  []string{"TERM=myterm"},
+			expected: ANSI,
+		},
+		{
+			name:     "TERMINFO",
+			environ:  []string{"TERM=myterm", "TERMINFO=" + dir},
+			expected: TrueColor,
+			reason:   "terminfo entry for TERM=myterm in " + dir + " has colors#16777216, RGB, supporting TrueColor",
+		},
+		{
+			name:     "TERMINFO_DIRS",
+			environ:  []string{"TERM=myterm", "TERMINFO_DIRS=/nonexistent" + string(filepath.ListSeparator) + dir},
+			expected: TrueColor,
+		},
+		{
+			name:     "HOME",
+			environ:  []string{"TERM=myterm", "HOME=" + home},
+			expected: TrueColor,
+		},
+		{
+			name:     "built-in",
+			environ:  []string{"TERM=iterm2"},
+			expected: ANSI256,
+			reason:   "terminfo entry for TERM=iterm2 in the built-in database has colors#256, supporting ANSI256",
+		},
+		{
+			name:     "built-in without colors",
+			environ:  []string{"TERM=vt100"},
+			expected: ANSI,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Detector{IsTerminal: fakeTerminal}
+			e := d.Explain(&fakeFile{fd: 42}, tc.environ)
+			if e.Profile != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, e.Profile)
+			}
+			if len(tc.reason) > 0 && !containsReason(e, tc.reason) {
+				t.Errorf("expected explanation to mention %q, got:\n%s", tc.reason, e)
+			}
+		})
+	}
+}
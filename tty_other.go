@@ -0,0 +1,58 @@
+//go:build !windows
+// +build !windows
+
+package colorprofile
+
+import (
+	"io"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// tty is the controlling terminal opened in raw mode.
+type tty struct {
+	*os.File
+	state *term.State
+}
+
+// openTTY opens the controlling terminal in raw mode, so query replies can be
+// read without waiting for a newline or being echoed.
+func openTTY() (io.ReadWriteCloser, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	// Don't use f.Fd() as it puts the file in blocking mode, which disables
+	// read deadlines.
+	conn, err := f.SyscallConn()
+	if err != nil {
+		_ = f.Close()
+		return nil, err //nolint:wrapcheck
+	}
+
+	var state *term.State
+	if cerr := conn.Control(func(fd uintptr) {
+		state, err = term.MakeRaw(fd)
+	}); cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &tty{File: f, state: state}, nil
+}
+
+// Close restores the terminal state and closes it.
+func (t *tty) Close() error {
+	conn, err := t.SyscallConn()
+	if err == nil {
+		_ = conn.Control(func(fd uintptr) {
+			_ = term.Restore(fd, t.state)
+		})
+	}
+	return t.File.Close() //nolint:wrapcheck
+}
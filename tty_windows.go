@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package colorprofile
+
+import (
+	"errors"
+	"io"
+)
+
+// openTTY isn't supported on Windows, where the console input and output are
+// separate handles.
+func openTTY() (io.ReadWriteCloser, error) {
+	return nil, errors.ErrUnsupported
+}
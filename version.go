@@ -0,0 +1,49 @@
+package colorprofile
+
+import "strings"
+
+// versionAtLeast reports whether the dotted version v is greater than or
+// equal to minimum. Each component is compared numerically using its leading
+// digits, so "3.3a" compares as "3.3". Missing components are treated as
+// zero. An empty or unparseable v is never at least minimum.
+func versionAtLeast(v, minimum string) bool {
+	if len(v) == 0 || !isDigit(v[0]) {
+		return false
+	}
+
+	vs := strings.Split(v, ".")
+	ms := strings.Split(minimum, ".")
+	for i := range max(len(vs), len(ms)) {
+		var a, b int
+		if i < len(vs) {
+			a = leadingInt(vs[i])
+		}
+		if i < len(ms) {
+			b = leadingInt(ms[i])
+		}
+		if a != b {
+			return a > b
+		}
+	}
+
+	return true
+}
+
+// leadingInt returns the integer value of the leading digits of s.
+func leadingInt(s string) (n int) {
+	for i := 0; i < len(s) && isDigit(s[i]); i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	return
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// versionBefore reports whether the dotted version v is known and less than
+// maximum. Unlike !versionAtLeast, an empty or unparseable v is never before
+// maximum.
+func versionBefore(v, maximum string) bool {
+	return len(v) > 0 && isDigit(v[0]) && !versionAtLeast(v, maximum)
+}
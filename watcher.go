@@ -0,0 +1,310 @@
+package colorprofile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Trigger tells a [Watcher] when to detect the color profile again.
+type Trigger interface {
+	// Watch calls notify whenever the color profile may have changed, until
+	// stop is closed.
+	Watch(notify func(), stop <-chan struct{})
+}
+
+// TriggerFunc adapts a function to a [Trigger].
+type TriggerFunc func(notify func(), stop <-chan struct{})
+
+// Watch calls f.
+func (f TriggerFunc) Watch(notify func(), stop <-chan struct{}) {
+	f(notify, stop)
+}
+
+// SignalTrigger returns a trigger firing when the process receives one of
+// the signals.
+func SignalTrigger(sigs ...os.Signal) Trigger {
+	return TriggerFunc(func(notify func(), stop <-chan struct{}) {
+		if len(sigs) == 0 {
+			return
+		}
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, sigs...)
+		defer signal.Stop(c)
+		for {
+			select {
+			case <-c:
+				notify()
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// DefaultTriggers returns the triggers firing when a process may have moved
+// to another terminal: SIGCONT, sent when a stopped process is foregrounded.
+// There are none on Windows. Use [Detector.TmuxTrigger] to notice tmux
+// sessions reattached from another terminal. SIGWINCH isn't one: terminals
+// send bursts of it while windows are resized, without changing terminal.
+func DefaultTriggers() []Trigger {
+	if len(redetectSignals) == 0 {
+		return nil
+	}
+	return []Trigger{SignalTrigger(redetectSignals...)}
+}
+
+// defaultTmuxInterval is the interval TmuxTrigger polls the tmux client at
+// when the given one isn't positive.
+const defaultTmuxInterval = time.Second
+
+// TmuxTrigger returns a trigger firing when the tmux client of the session
+// changes, e.g. when the session is detached and reattached from another
+// terminal, which may support other colors. The client is polled every
+// interval, or every second if interval isn't positive, with
+// `tmux display-message`. If env, usually the one given to [Detector.Watch],
+// doesn't run in tmux, the trigger does nothing. It can only be used by one
+// watcher.
+func (d *Detector) TmuxTrigger(env []string, interval time.Duration) Trigger {
+	if tmux, ok := newEnviron(env).lookup("TMUX"); !ok || len(tmux) == 0 {
+		return TriggerFunc(func(func(), <-chan struct{}) {})
+	}
+	if interval <= 0 {
+		interval = defaultTmuxInterval
+	}
+
+	run := d.runCommand()
+	client := func() []byte {
+		out, err := run("tmux", "display-message", "-p", "#{client_tty} #{client_termname} #{client_termfeatures}")
+		if err != nil {
+			return nil
+		}
+		return out
+	}
+
+	// Compare with the client when the trigger is created, so changes
+	// before it starts watching aren't missed.
+	last := client()
+	return TriggerFunc(func(notify func(), stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if c := client(); !bytes.Equal(c, last) {
+					last = c
+					notify()
+				}
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// Watcher keeps the color profile of an output up to date, detecting it
+// again when a trigger fires or [Watcher.Detect] is called. It's safe for
+// concurrent use.
+type Watcher struct {
+	detector *Detector
+	output   io.Writer
+	env      []string
+
+	state atomic.Pointer[watchState]
+
+	// mu serializes the detections and guards the change callbacks.
+	mu       sync.Mutex
+	onChange []func(Profile)
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// watchDelay is how long a watcher waits after a trigger fires before
+// detecting the color profile again, so the triggers firing meanwhile cause
+// a single detection.
+const watchDelay = 100 * time.Millisecond
+
+// watchState is the result of a detection of a watcher.
+type watchState struct {
+	Explanation
+	// console is the legacy Windows console to translate styles for, if
+	// any.
+	console ConsoleSink
+}
+
+// Watch detects the color profile of output, and detects it again shortly
+// after the triggers fire, until the watcher is closed. Triggers firing in a
+// burst cause a single detection. See [DefaultTriggers] and
+// [Detector.TmuxTrigger].
+//
+// The color profile is the one of [Detector.NewWriter], so legacy Windows
+// consoles are ANSI, with styles translated into console attributes.
+//
+// Every detection uses env, as a process environment doesn't change when it
+// resumes or its tmux session is reattached. Only what's queried from the
+// output and tmux is detected again, not e.g. a new TERM or COLORTERM.
+func (d *Detector) Watch(output io.Writer, env []string, triggers ...Trigger) *Watcher {
+	w := &Watcher{
+		detector: d,
+		output:   output,
+		env:      env,
+		stop:     make(chan struct{}),
+	}
+	w.state.Store(w.detect())
+
+	if len(triggers) == 0 {
+		return w
+	}
+	notify := make(chan struct{}, 1)
+	for _, t := range triggers {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			t.Watch(func() {
+				select {
+				case notify <- struct{}{}:
+				default:
+					// A detection is already pending.
+				}
+			}, w.stop)
+		}()
+	}
+	w.wg.Add(1)
+	go w.detectLoop(notify)
+	return w
+}
+
+// detectLoop detects the color profile again a delay after the triggers
+// notify, at most once per delay, until the watcher is closed.
+func (w *Watcher) detectLoop(notify <-chan struct{}) {
+	defer w.wg.Done()
+	timer := time.NewTimer(watchDelay)
+	timer.Stop()
+	defer timer.Stop()
+	var pending bool
+	for {
+		select {
+		case <-notify:
+			if !pending {
+				pending = true
+				timer.Reset(watchDelay)
+			}
+		case <-timer.C:
+			pending = false
+			w.Detect()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// detect detects the color profile like [Detector.NewWriter].
+func (w *Watcher) detect() *watchState {
+	s := w.detector.newDetection(w.env)
+	s.translate = true
+	p := s.detect(w.output)
+	st := &watchState{Explanation: Explanation{Profile: p, Reasons: s.reasons, Limits: s.limits}}
+	if s.legacy && p > NoTTY {
+		st.console = s.sink
+	}
+	return st
+}
+
+// Profile returns the current color profile.
+func (w *Watcher) Profile() Profile {
+	return w.state.Load().Profile
+}
+
+// Explanation returns how the current color profile was detected.
+func (w *Watcher) Explanation() Explanation {
+	return w.state.Load().Explanation
+}
+
+// Detect detects the color profile again, e.g. after the application
+// resumes, and returns it. The change callbacks are called if the profile,
+// its limits, or the console translation changed.
+func (w *Watcher) Detect() Profile {
+	w.mu.Lock()
+	st := w.detect()
+	old := w.state.Swap(st)
+	var onChange []func(Profile)
+	if st.changed(old) {
+		onChange = w.onChange
+	}
+	w.mu.Unlock()
+
+	// The callbacks are called without the lock, so they can use the
+	// watcher.
+	for _, f := range onChange {
+		f(st.Profile)
+	}
+	return st.Profile
+}
+
+// changed tells whether the detection differs from old in what writers
+// depend on, ignoring the reasons.
+func (st *watchState) changed(old *watchState) bool {
+	return st.Profile != old.Profile || st.Limits != old.Limits ||
+		(st.console == nil) != (old.console == nil)
+}
+
+// OnChange registers a function called with the new color profile whenever
+// the detection changes, see [Watcher.Detect]. Use [Watcher.Explanation] for
+// the limits. The functions are called one at a time for each detection,
+// after it completes, so they may call the watcher methods. Concurrent
+// detections may call them concurrently.
+func (w *Watcher) OnChange(f func(Profile)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, f)
+}
+
+// Writer returns a writer downsampling the colors written to forward to the
+// current color profile, and degrading the styles the terminal doesn't
+// render like [Detector.NewWriter]. Each write uses the profile current when
+// it starts.
+func (w *Watcher) Writer(forward io.Writer) io.Writer {
+	ww := &watcherWriter{watcher: w, out: Output{Writer: forward}}
+	ww.w.Forward = &ww.out
+	return ww
+}
+
+// Close stops the triggers.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+	})
+	w.wg.Wait()
+	return nil
+}
+
+// watcherWriter is a writer using the current color profile of a watcher.
+type watcherWriter struct {
+	watcher *Watcher
+
+	// mu serializes the writes, which share the console attributes.
+	mu    sync.Mutex
+	state *watchState
+	w     Writer
+	out   Output
+}
+
+// Write writes p with the current color profile.
+func (w *watcherWriter) Write(p []byte) (int, error) {
+	st := w.watcher.state.Load()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if st != w.state {
+		w.state = st
+		w.w.Profile = st.Profile
+		w.out.Console, w.out.Limits = st.console, st.Limits
+	}
+	return w.w.Write(p)
+}
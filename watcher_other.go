@@ -0,0 +1,10 @@
+//go:build !unix
+// +build !unix
+
+package colorprofile
+
+import "os"
+
+// redetectSignals are the signals telling the process may have moved to
+// another terminal. There are none on Windows and other non-Unix systems.
+var redetectSignals []os.Signal
@@ -0,0 +1,357 @@
+package colorprofile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// tmuxEnv is a tmux session whose client terminal may change.
+var tmuxEnv = []string{
+	"TERM=tmux-256color",
+	"TMUX=/tmp/tmux-1000/default,1,0",
+	"COLORTERM=truecolor",
+}
+
+// switchingRunner is a command runner whose `tmux info` output can change,
+// like when a tmux session is reattached from another terminal.
+type switchingRunner struct {
+	info   atomic.Value
+	client atomic.Value
+}
+
+func newSwitchingRunner(info, client string) *switchingRunner {
+	r := new(switchingRunner)
+	r.set(info, client)
+	return r
+}
+
+func (r *switchingRunner) set(info, client string) {
+	r.info.Store(info)
+	r.client.Store(client)
+}
+
+func (r *switchingRunner) run(_ string, args ...string) ([]byte, error) {
+	if len(args) > 0 && args[0] == "display-message" {
+		return []byte(r.client.Load().(string)), nil
+	}
+	return []byte(r.info.Load().(string)), nil
+}
+
+func TestWatcher(t *testing.T) {
+	r := newSwitchingRunner(tmuxInfoTc, "")
+	d := Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo, RunCommand: r.run}
+
+	fire := make(chan struct{})
+	trigger := TriggerFunc(func(notify func(), stop <-chan struct{}) {
+		for {
+			select {
+			case <-fire:
+				notify()
+			case <-stop:
+				return
+			}
+		}
+	})
+
+	w := d.Watch(&fakeFile{fd: 42}, tmuxEnv, trigger)
+	defer w.Close() //nolint:errcheck
+
+	changes := make(chan Profile, 1)
+	w.OnChange(func(p Profile) { changes <- p })
+
+	if p := w.Profile(); p != TrueColor {
+		t.Fatalf("expected TrueColor, got %v", p)
+	}
+
+	var buf bytes.Buffer
+	out := w.Writer(&buf)
+	_, _ = io.WriteString(out, "\x1b[38;2;107;80;255mhi\x1b[m")
+
+	// Reattach from a terminal without true colors.
+	r.set(" 196: Tc: [missing]\n", "")
+	fire <- struct{}{}
+	select {
+	case p := <-changes:
+		if p != ANSI256 {
+			t.Errorf("expected ANSI256, got %v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the profile didn't change")
+	}
+	if p := w.Profile(); p != ANSI256 {
+		t.Errorf("expected ANSI256, got %v", p)
+	}
+	if e := w.Explanation(); !containsReason(e, "running under tmux, which passes ANSI256 through") {
+		t.Errorf("expected the tmux reason, got:\n%s", e)
+	}
+
+	_, _ = io.WriteString(out, "\x1b[38;2;107;80;255mhi\x1b[m")
+	if expected := "\x1b[38;2;107;80;255mhi\x1b[m\x1b[38;5;63mhi\x1b[m"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// Detecting the same profile again doesn't call the callbacks.
+	if p := w.Detect(); p != ANSI256 {
+		t.Errorf("expected ANSI256, got %v", p)
+	}
+	select {
+	case p := <-changes:
+		t.Errorf("unexpected change to %v", p)
+	default:
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("unexpected error closing twice: %v", err)
+	}
+}
+
+func TestWatcherTmuxTrigger(t *testing.T) {
+	r := newSwitchingRunner(tmuxInfoTc, "/dev/pts/1 xterm-256color RGB")
+	d := Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo, RunCommand: r.run}
+
+	w := d.Watch(&fakeFile{fd: 42}, tmuxEnv, d.TmuxTrigger(tmuxEnv, time.Millisecond))
+	defer w.Close() //nolint:errcheck
+
+	changes := make(chan Profile, 1)
+	w.OnChange(func(p Profile) { changes <- p })
+
+	r.set(" 196: Tc: [missing]\n", "/dev/pts/2 xterm-256color")
+	select {
+	case p := <-changes:
+		if p != ANSI256 {
+			t.Errorf("expected ANSI256, got %v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the tmux client change wasn't noticed")
+	}
+}
+
+func TestTmuxTriggerSettings(t *testing.T) {
+	var runs atomic.Int32
+	d := Detector{
+		IsTerminal:   fakeTerminal,
+		LoadTerminfo: noTerminfo,
+		RunCommand: func(string, ...string) ([]byte, error) {
+			runs.Add(1)
+			return nil, errNotFound
+		},
+	}
+
+	// Outside tmux, the trigger doesn't poll.
+	env := []string{"TERM=xterm-256color"}
+	w := d.Watch(&fakeFile{fd: 42}, env, d.TmuxTrigger(env, time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	_ = w.Close()
+	if n := runs.Load(); n > 0 {
+		t.Errorf("expected no tmux commands outside tmux, got %d", n)
+	}
+
+	// A non-positive interval uses the default one instead of panicking.
+	w = d.Watch(&fakeFile{fd: 42}, tmuxEnv, d.TmuxTrigger(tmuxEnv, 0))
+	_ = w.Close()
+}
+
+func TestWatcherOnChange(t *testing.T) {
+	d := Detector{
+		IsTerminal:   fakeTerminal,
+		LoadTerminfo: noTerminfo,
+		Terminals:    []Terminal{{Term: "xterm-256color", Profile: ANSI256}},
+	}
+	w := d.Watch(&fakeFile{fd: 42}, []string{"TERM=xterm-256color"})
+	defer w.Close() //nolint:errcheck
+
+	// The callbacks can use the watcher.
+	var limits []Limits
+	w.OnChange(func(p Profile) {
+		if w.Profile() != p {
+			t.Errorf("expected %v, got %v", p, w.Profile())
+		}
+		limits = append(limits, w.Explanation().Limits)
+		w.OnChange(func(Profile) {})
+		w.Detect()
+	})
+
+	// A change to the limits alone is a change.
+	d.Terminals[0].Limits = Limits{NoBrightBackground: true}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if p := w.Detect(); p != ANSI256 {
+			t.Errorf("expected ANSI256, got %v", p)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the detection deadlocked")
+	}
+	if expected := []Limits{{NoBrightBackground: true}}; !reflect.DeepEqual(limits, expected) {
+		t.Errorf("expected %v, got %v", expected, limits)
+	}
+}
+
+func TestWatcherSignalTrigger(t *testing.T) {
+	if len(redetectSignals) == 0 {
+		t.Skip("no signals to trigger detection with")
+	}
+
+	r := newSwitchingRunner(tmuxInfoTc, "")
+	d := Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo, RunCommand: r.run}
+	w := d.Watch(&fakeFile{fd: 42}, tmuxEnv, DefaultTriggers()...)
+	defer w.Close() //nolint:errcheck
+
+	changes := make(chan Profile, 1)
+	w.OnChange(func(p Profile) { changes <- p })
+
+	r.set(" 196: Tc: [missing]\n", "")
+	proc, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The signal handler may not be installed yet, so signal until the
+	// change is noticed.
+	timeout := time.After(5 * time.Second)
+	for {
+		if err := proc.Signal(redetectSignals[0]); err != nil {
+			t.Fatalf("can't signal the process: %v", err)
+		}
+		select {
+		case p := <-changes:
+			if p != ANSI256 {
+				t.Errorf("expected ANSI256, got %v", p)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("the signal wasn't noticed")
+		}
+	}
+}
+
+func TestWatcherConcurrency(t *testing.T) {
+	r := newSwitchingRunner(tmuxInfoTc, "")
+	d := Detector{IsTerminal: fakeTerminal, LoadTerminfo: noTerminfo, RunCommand: r.run}
+	w := d.Watch(&fakeFile{fd: 42}, tmuxEnv)
+	defer w.Close() //nolint:errcheck
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := w.Writer(io.Discard)
+			for range 100 {
+				_, _ = io.WriteString(out, "\x1b[38;2;107;80;255mhi\x1b[m")
+				_ = w.Profile()
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 20 {
+				if (i+j)%2 == 0 {
+					r.set(tmuxInfoTc, "")
+				} else {
+					r.set(" 196: Tc: [missing]\n", "")
+				}
+				w.Detect()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestWatcherBurst(t *testing.T) {
+	r := newSwitchingRunner(tmuxInfoTc, "")
+	var detections atomic.Int32
+	d := Detector{
+		IsTerminal:   fakeTerminal,
+		LoadTerminfo: noTerminfo,
+		RunCommand: func(name string, args ...string) ([]byte, error) {
+			if len(args) > 0 && args[0] == "info" {
+				detections.Add(1)
+			}
+			return r.run(name, args...)
+		},
+	}
+
+	fire := make(chan struct{})
+	trigger := TriggerFunc(func(notify func(), stop <-chan struct{}) {
+		for {
+			select {
+			case <-fire:
+				notify()
+			case <-stop:
+				return
+			}
+		}
+	})
+	w := d.Watch(&fakeFile{fd: 42}, tmuxEnv, trigger)
+	defer w.Close() //nolint:errcheck
+
+	changes := make(chan Profile, 1)
+	w.OnChange(func(p Profile) { changes <- p })
+
+	// A burst of triggers, like signals sent while a window is resized.
+	detections.Store(0)
+	r.set(" 196: Tc: [missing]\n", "")
+	for range 50 {
+		fire <- struct{}{}
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the profile didn't change")
+	}
+	time.Sleep(2 * watchDelay)
+	if n := detections.Load(); n < 1 || n > 5 {
+		t.Errorf("expected the burst to be coalesced, got %d detections", n)
+	}
+}
+
+func TestWatcherConsole(t *testing.T) {
+	c := &legacyConsole{fakeConsole: fakeConsole{mode: 0x3}}
+	d := Detector{
+		GOOS:         "windows",
+		IsTerminal:   fakeTerminal,
+		LoadTerminfo: noTerminfo,
+		WindowsVersion: func() (uint32, uint32) {
+			return 6, 7601
+		},
+		OpenConsole: func(uintptr) (Console, error) {
+			return c, nil
+		},
+	}
+	w := d.Watch(&fakeFile{fd: 42}, nil)
+	defer w.Close() //nolint:errcheck
+	if p := w.Profile(); p != ANSI {
+		t.Fatalf("expected ANSI, got %v", p)
+	}
+
+	var buf bytes.Buffer
+	out := w.Writer(&buf)
+	_, _ = io.WriteString(out, "\x1b[1;31mhi")
+	_, _ = io.WriteString(out, "\x1b[m")
+	if buf.String() != "hi" {
+		t.Errorf("expected %q, got %q", "hi", buf.String())
+	}
+	expected := []any{
+		ConsoleAttributes{Bold: true, Foreground: ansi.Red},
+		ConsoleAttributes{},
+	}
+	if !reflect.DeepEqual(c.ops, expected) {
+		t.Errorf("expected console attributes %v, got %v", expected, c.ops)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build unix
+// +build unix
+
+package colorprofile
+
+import (
+	"os"
+	"syscall"
+)
+
+// redetectSignals are the signals telling the process may have moved to
+// another terminal.
+var redetectSignals = []os.Signal{syscall.SIGCONT}
@@ -0,0 +1,54 @@
+package colorprofile
+
+import "strconv"
+
+// windowsProfile returns the color profile of the Windows console based on
+// the environment and the Windows NT major version and build number.
+func windowsProfile(env environ, major, build uint32) Profile {
+	if env["ConEmuANSI"] == "ON" {
+		return TrueColor
+	}
+
+	if build < 10586 || major < 10 {
+		// No ANSI support before WindowsNT 10 build 10586
+		if len(env["ANSICON"]) > 0 {
+			ansiconVer := env["ANSICON_VER"]
+			cv, err := strconv.Atoi(ansiconVer)
+			if err != nil || cv < 181 {
+				// No 8 bit color support before ANSICON 1.81
+				return ANSI
+			}
+
+			return ANSI256
+		}
+
+		return NoTTY
+	}
+
+	if build < 14931 {
+		// No true color support before build 14931
+		return ANSI256
+	}
+
+	return TrueColor
+}
+
+// consoleProfile returns the color profile of a Windows console given its
+// probed state, the profile the environment and Windows version suggest, and
+// whether styles can be translated into console attributes. legacy tells
+// whether they have to be, because the console doesn't process escape
+// sequences and neither ConEmu nor ANSICON translate them.
+func consoleProfile(env environ, state ConsoleState, p Profile, translate bool) (_ Profile, legacy bool) {
+	switch {
+	case state.VT:
+		return max(state.Profile, p), false
+	case env["ConEmuANSI"] == "ON" || len(env["ANSICON"]) > 0:
+		return p, false
+	case translate:
+		return state.Profile, true
+	default:
+		// Applications commonly enable VT processing themselves, so keep the
+		// profile the Windows version supports.
+		return p, false
+	}
+}
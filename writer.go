@@ -6,8 +6,11 @@ import (
 	"image/color"
 	"io"
 	"strconv"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/ansi/parser"
 )
 
 // NewWriter creates a new color profile writer that downgrades color sequences
@@ -19,56 +22,237 @@ import (
 // If it does, along with the given environment variables, it will determine
 // the appropriate color profile to use for color formatting.
 //
+// On legacy Windows consoles, which don't render escape sequences, the writer
+// translates the styles into console attributes. See [Output].
+//
 // This respects the NO_COLOR, CLICOLOR, and CLICOLOR_FORCE environment variables.
 func NewWriter(w io.Writer, environ []string) *Writer {
-	return &Writer{
-		Forward: w,
-		Profile: Detect(w, environ),
-	}
+	return new(Detector).NewWriter(w, environ)
 }
 
 // Writer represents a color profile writer that writes ANSI sequences to the
 // underlying writer.
+//
+// If Forward is an [*Output], the writer also follows its terminal
+// constraints, and writes the text to the writer it wraps.
 type Writer struct {
 	Forward io.Writer
 	Profile Profile
 }
 
+// Output is a [Writer.Forward] writer describing what the terminal doesn't
+// render beyond the limits of its color profile. [Detector.NewWriter] wraps
+// the output in an Output when needed.
+//
+//	w := &colorprofile.Writer{
+//		Forward: &colorprofile.Output{Writer: os.Stdout, Limits: e.Limits},
+//		Profile: e.Profile,
+//	}
+type Output struct {
+	// Writer is the writer the text is written to.
+	io.Writer
+
+	// Console, if not nil, receives the styles as legacy Windows console
+	// attributes instead of SGR sequences, which legacy consoles don't
+	// render. Colors are converted to the 16 ANSI colors, other escape
+	// sequences are stripped, and the text is still written to Writer.
+	Console ConsoleSink
+
+	// Limits are the colors and attributes the terminal doesn't render
+	// despite its profile, such as the bright backgrounds and italics on the
+	// Linux console. Styles are degraded accordingly.
+	Limits Limits
+
+	// attrs are the console attributes set so far.
+	attrs ConsoleAttributes
+}
+
+// newOutput returns w wrapped in an [Output] if the terminal has a legacy
+// console or limits, and w otherwise.
+func newOutput(w io.Writer, console ConsoleSink, limits Limits) io.Writer {
+	if console == nil && limits == (Limits{}) {
+		return w
+	}
+	return &Output{Writer: w, Console: console, Limits: limits}
+}
+
+// output returns the writer the text is written to, and the terminal
+// constraints if Forward is an [*Output].
+func (w *Writer) output() (io.Writer, *Output) {
+	if o, ok := w.Forward.(*Output); ok && o != nil {
+		return o.Writer, o
+	}
+	return w.Forward, nil
+}
+
+// limits returns the limits of the output, if any.
+func (o *Output) limits() Limits {
+	if o == nil {
+		return Limits{}
+	}
+	return o.Limits
+}
+
 // Write writes the given text to the underlying writer.
 func (w *Writer) Write(p []byte) (int, error) {
+	return write(w, p)
+}
+
+// WriteString writes the given text to the underlying writer, without
+// copying it into a byte slice first.
+func (w *Writer) WriteString(s string) (n int, err error) {
+	return write(w, s)
+}
+
+// write writes the given text to the underlying writer.
+func write[T string | []byte](w *Writer, p T) (int, error) {
+	fw, o := w.output()
 	switch {
-	case w.Profile == TrueColor:
-		return w.Forward.Write(p) //nolint:wrapcheck
+	case o != nil && o.Console != nil && w.Profile > NoTTY:
+		_, err := o.writeConsole(w.Profile, []byte(p))
+		return len(p), err
+	case w.Profile == TrueColor && o.limits() == (Limits{}):
+		return forward(fw, p)
 	case w.Profile <= NoTTY:
-		_, err := io.WriteString(w.Forward, ansi.Strip(string(p)))
+		_, err := strip(fw, p)
 		return len(p), err
-	case w.Profile == ASCII, w.Profile == ANSI, w.Profile == ANSI256:
-		_, err := w.downsample(p)
+	case w.Profile == ASCII, w.Profile == ANSI, w.Profile == ANSI256, w.Profile == TrueColor:
+		_, err := downsample(fw, w.Profile, o.limits(), p)
 		return len(p), err
 	default:
 		return 0, fmt.Errorf("invalid profile: %v", w.Profile)
 	}
 }
 
-// downsample downgrades the given text to the appropriate color profile.
-func (w *Writer) downsample(p []byte) (int, error) {
-	var buf bytes.Buffer
+// forward writes the given text to w as is.
+func forward[T string | []byte](w io.Writer, p T) (int, error) {
+	switch p := any(p).(type) {
+	case string:
+		return io.WriteString(w, p) //nolint:wrapcheck
+	case []byte:
+		return w.Write(p) //nolint:wrapcheck
+	}
+	return 0, nil
+}
+
+// maxPooledBuffer is the largest buffer capacity kept in bufferPool, so that
+// a single huge write doesn't pin its memory.
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds the buffers downsample writes the output into.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from bufferPool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer) //nolint:forcetypeassert
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns a buffer to bufferPool, unless it grew too large.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// strip writes the given text to w without its escape sequences, like
+// [ansi.Strip] but without copying the text into a new string.
+func strip[T string | []byte](w io.Writer, p T) (int, error) {
+	if plainLen(p) == len(p) {
+		return forward(w, p)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	// This is the state machine of ansi.Strip, keeping the printable and
+	// control characters.
+	var ri, rw int // index and width of the current UTF-8 rune
+	pstate := parser.GroundState
+	for i := range len(p) {
+		if pstate == parser.Utf8State {
+			buf.WriteByte(p[i])
+			ri++
+			if ri < rw {
+				continue
+			}
+			pstate = parser.GroundState
+			ri, rw = 0, 0
+			continue
+		}
+
+		state, action := parser.Table.Transition(pstate, p[i])
+		switch action {
+		case parser.CollectAction:
+			if state == parser.Utf8State {
+				rw = utf8ByteLen(p[i])
+				buf.WriteByte(p[i])
+				ri++
+			}
+		case parser.PrintAction, parser.ExecuteAction:
+			buf.WriteByte(p[i])
+		}
+		pstate = state
+	}
+
+	return w.Write(buf.Bytes()) //nolint:wrapcheck
+}
+
+// utf8ByteLen returns the length of the UTF-8 rune starting with b, or -1 if
+// b can't start one.
+func utf8ByteLen(b byte) int {
+	switch {
+	case b < utf8.RuneSelf:
+		return 1
+	case b >= 0xC0 && b <= 0xDF: //nolint:mnd
+		return 2 //nolint:mnd
+	case b >= 0xE0 && b <= 0xEF: //nolint:mnd
+		return 3 //nolint:mnd
+	case b >= 0xF0 && b <= 0xF7: //nolint:mnd
+		return 4 //nolint:mnd
+	}
+	return -1
+}
+
+// downsample downgrades the given text to the color profile and terminal
+// limits, and writes it to w.
+func downsample[T string | []byte](w io.Writer, profile Profile, limits Limits, p T) (int, error) {
+	// Text without escape sequences is written as is, without copying.
+	if plainLen(p) == len(p) {
+		return forward(w, p)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
 	var state byte
 
 	parser := ansi.GetParser()
 	defer ansi.PutParser(parser)
 
 	for len(p) > 0 {
+		if state == ansi.NormalState {
+			// Copy runs of plain text at once.
+			if n := plainLen(p); n > 0 {
+				_, _ = forward(buf, p[:n])
+				p = p[n:]
+				continue
+			}
+		}
+
 		parser.Reset()
 		seq, _, read, newState := ansi.DecodeSequence(p, state, parser)
 
 		switch {
 		case ansi.HasCsiPrefix(seq) && parser.Command() == 'm':
-			handleSgr(w, parser, &buf)
+			handleSgr(profile, limits, parser, buf)
 		default:
 			// If we're not a style SGR sequence, just write the bytes.
-			if n, err := buf.Write(seq); err != nil {
-				return n, err //nolint:wrapcheck
+			if n, err := forward(buf, seq); err != nil {
+				return n, err
 			}
 		}
 
@@ -76,96 +260,533 @@ func (w *Writer) downsample(p []byte) (int, error) {
 		state = newState
 	}
 
-	return w.Forward.Write(buf.Bytes()) //nolint:wrapcheck
+	return w.Write(buf.Bytes()) //nolint:wrapcheck
 }
 
-// WriteString writes the given text to the underlying writer.
-func (w *Writer) WriteString(s string) (n int, err error) {
-	return w.Write([]byte(s))
+// plainLen returns the length of the plain text at the start of p, that is
+// printable and control characters other than ESC, and valid UTF-8 runes,
+// which can't start an escape sequence.
+func plainLen[T string | []byte](p T) int {
+	for i := 0; i < len(p); {
+		switch c := p[i]; {
+		case c == ansi.ESC:
+			return i
+		case c < utf8.RuneSelf:
+			i++
+		case c >= 0xC0: //nolint:mnd
+			r, size := decodeRune(p[i:])
+			if r == utf8.RuneError && size <= 1 {
+				return i
+			}
+			i += size
+		default:
+			// C1 control characters, such as CSI.
+			return i
+		}
+	}
+	return len(p)
+}
+
+// decodeRune decodes the first UTF-8 rune of p.
+func decodeRune[T string | []byte](p T) (rune, int) {
+	switch p := any(p).(type) {
+	case string:
+		return utf8.DecodeRuneInString(p)
+	case []byte:
+		return utf8.DecodeRune(p)
+	}
+	return utf8.RuneError, 0
+}
+
+// readChunkSize is the size of the chunks ReadFrom reads.
+const readChunkSize = 32 << 10
+
+// chunkPool holds the buffers ReadFrom reads into.
+var chunkPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, readChunkSize)
+		return &b
+	},
 }
 
-func handleSgr(w *Writer, p *ansi.Parser, buf *bytes.Buffer) {
-	var style ansi.Style
+// ReadFrom reads text from r until EOF or an error, and writes it to the
+// underlying writer like [Writer.Write], in chunks. Escape sequences split
+// between two reads are kept whole. This makes [io.Copy] to a Writer efficient.
+func (w *Writer) ReadFrom(r io.Reader) (n int64, err error) {
+	if fw, o := w.output(); o == nil && w.Profile == TrueColor {
+		return io.Copy(fw, r) //nolint:wrapcheck
+	}
+
+	chunk := chunkPool.Get().(*[]byte) //nolint:forcetypeassert
+	defer chunkPool.Put(chunk)
+	buf := *chunk
+
+	var pending int // bytes carried over from the previous read
+	for {
+		if pending == len(buf) {
+			// An unterminated sequence fills the buffer, give up on keeping
+			// it whole.
+			if _, err := w.Write(buf); err != nil {
+				return n, err
+			}
+			pending = 0
+		}
+
+		m, rerr := r.Read(buf[pending:])
+		n += int64(m)
+		data := buf[:pending+m]
+
+		var cut int
+		if rerr == nil {
+			cut = incompleteTail(data)
+		}
+		if len(data)-cut > 0 {
+			if _, err := w.Write(data[:len(data)-cut]); err != nil {
+				return n, err
+			}
+		}
+		pending = copy(buf, data[len(data)-cut:])
+
+		if rerr == io.EOF {
+			return n, nil
+		}
+		if rerr != nil {
+			return n, rerr //nolint:wrapcheck
+		}
+	}
+}
+
+// incompleteTail returns the length of the escape sequence or UTF-8 rune at
+// the end of p that may continue in the next read.
+func incompleteTail(p []byte) int {
+	if i := bytes.LastIndexByte(p, ansi.ESC); i >= 0 {
+		_, _, read, state := ansi.DecodeSequence(p[i:], ansi.NormalState, nil)
+		if i+read == len(p) && state != ansi.NormalState {
+			return len(p) - i
+		}
+	}
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return len(p) - i
+			}
+			break
+		}
+	}
+	return 0
+}
+
+// writeConsole writes the text to the underlying writer, and translates the
+// SGR sequences into console attributes.
+func (o *Output) writeConsole(profile Profile, p []byte) (int, error) {
+	var buf bytes.Buffer
+	var state byte
+
+	parser := ansi.GetParser()
+	defer ansi.PutParser(parser)
+
+	for len(p) > 0 {
+		parser.Reset()
+		seq, width, read, newState := ansi.DecodeSequence(p, state, parser)
+
+		switch {
+		case ansi.HasCsiPrefix(seq) && parser.Command() == 'm':
+			attrs := o.attrs
+			handleConsoleSgr(profile, parser, &attrs)
+			if attrs == o.attrs {
+				break
+			}
+			// Flush the text written with the previous attributes first.
+			if _, err := o.Writer.Write(buf.Bytes()); err != nil {
+				return 0, err //nolint:wrapcheck
+			}
+			buf.Reset()
+			if err := o.Console.SetAttributes(attrs); err != nil {
+				return 0, err //nolint:wrapcheck
+			}
+			o.attrs = attrs
+		case width > 0 || len(seq) == 1 && seq[0] < ansi.SP:
+			// Text and control characters.
+			buf.Write(seq)
+		}
+
+		p = p[read:]
+		state = newState
+	}
+
+	return o.Writer.Write(buf.Bytes()) //nolint:wrapcheck
+}
+
+// handleSgr writes the SGR sequence of the parser to buf, downgraded to the
+// color profile and terminal limits. The parameters are written straight
+// into buf, without building an [ansi.Style].
+func handleSgr(profile Profile, limits Limits, p *ansi.Parser, buf *bytes.Buffer) {
+	sgr := sgrWriter{buf: buf}
+	buf.WriteString("\x1b[")
 	params := p.Params()
 	for i := 0; i < len(params); i++ {
 		param := params[i]
 
 		switch param := param.Param(0); param {
 		case 0:
-			// SGR default parameter is 0. We use an empty string to reduce the
-			// number of bytes written to the buffer.
-			style = append(style, "")
+			// SGR default parameter is 0. We use an empty parameter to reduce
+			// the number of bytes written to the buffer.
+			sgr.next()
 		case 30, 31, 32, 33, 34, 35, 36, 37: // 8-bit foreground color
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.ForegroundColor(
-				w.Profile.Convert(ansi.BasicColor(param - 30))) //nolint:gosec
+			sgr.color(sgrForeground, basicColor(param-30))
 		case 38: // 16 or 24-bit foreground color
-			var c color.Color
-			if n := ansi.ReadStyleColor(params[i:], &c); n > 0 {
+			c, n := readColor(profile, params[i:])
+			if n > 0 {
 				i += n - 1
 			}
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.ForegroundColor(w.Profile.Convert(c))
+			sgr.color(sgrForeground, c)
 		case 39: // default foreground color
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.ForegroundColor(nil)
+			sgr.color(sgrForeground, sgrColor{})
 		case 40, 41, 42, 43, 44, 45, 46, 47: // 8-bit background color
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.BackgroundColor(
-				w.Profile.Convert(ansi.BasicColor(param - 40))) //nolint:gosec
+			sgr.color(sgrBackground, basicColor(param-40).background(limits))
 		case 48: // 16 or 24-bit background color
-			var c color.Color
-			if n := ansi.ReadStyleColor(params[i:], &c); n > 0 {
+			c, n := readColor(profile, params[i:])
+			if n > 0 {
 				i += n - 1
 			}
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.BackgroundColor(w.Profile.Convert(c))
+			sgr.color(sgrBackground, c.background(limits))
 		case 49: // default background color
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.BackgroundColor(nil)
+			sgr.color(sgrBackground, sgrColor{})
 		case 58: // 16 or 24-bit underline color
-			var c color.Color
-			if n := ansi.ReadStyleColor(params[i:], &c); n > 0 {
+			c, n := readColor(profile, params[i:])
+			if n > 0 {
 				i += n - 1
 			}
-			if w.Profile < ANSI {
+			if profile < ANSI || limits.Unsupported&AttrUnderlineColor != 0 {
 				continue
 			}
-			style = style.UnderlineColor(w.Profile.Convert(c))
+			sgr.color(sgrUnderline, c)
 		case 59: // default underline color
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.UnderlineColor(nil)
+			sgr.color(sgrUnderline, sgrColor{})
 		case 90, 91, 92, 93, 94, 95, 96, 97: // 8-bit bright foreground color
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.ForegroundColor(
-				w.Profile.Convert(ansi.BasicColor(param - 90 + 8))) //nolint:gosec
+			sgr.color(sgrForeground, basicColor(param-90+8))
 		case 100, 101, 102, 103, 104, 105, 106, 107: // 8-bit bright background color
-			if w.Profile < ANSI {
+			if profile < ANSI {
 				continue
 			}
-			style = style.BackgroundColor(
-				w.Profile.Convert(ansi.BasicColor(param - 100 + 8))) //nolint:gosec
+			sgr.color(sgrBackground, basicColor(param-100+8).background(limits))
 		default:
+			if attr, ok := sgrAttrs[param]; ok && limits.Unsupported&attr != 0 {
+				// Skip the unsupported attribute, and its sub-parameters,
+				// e.g. 4:3 for curly underlines.
+				for params[i].HasMore() && i+1 < len(params) {
+					i++
+				}
+				continue
+			}
 			// If this is not a color attribute, just append it to the style.
-			style = append(style, strconv.Itoa(param))
+			sgr.next()
+			sgr.int(param)
+		}
+	}
+	buf.WriteByte('m')
+}
+
+// The SGR parameters of the colors.
+const (
+	sgrForeground = 30
+	sgrBackground = 40
+	sgrUnderline  = 50
+)
+
+// sgrWriter writes the parameters of an SGR sequence to a buffer, separated
+// by semicolons.
+type sgrWriter struct {
+	buf *bytes.Buffer
+	n   int
+}
+
+// next starts a new parameter.
+func (w *sgrWriter) next() {
+	if w.n > 0 {
+		w.buf.WriteByte(';')
+	}
+	w.n++
+}
+
+// int writes an integer to the current parameter.
+func (w *sgrWriter) int(v int) {
+	w.buf.Write(strconv.AppendInt(w.buf.AvailableBuffer(), int64(v), 10))
+}
+
+// color writes the parameters of a foreground, background, or underline
+// color like [ansi.Style]. base is one of sgrForeground, sgrBackground, and
+// sgrUnderline.
+func (w *sgrWriter) color(base int, c sgrColor) {
+	w.next()
+	switch c.kind {
+	case sgrBasic:
+		switch {
+		case base == sgrUnderline:
+			// Underline colors have no basic color parameters.
+			w.indexed(base, int(c.value))
+			return
+		case c.value < 8: //nolint:mnd
+			w.int(base + int(c.value))
+			return
+		case c.value < 16: //nolint:mnd
+			w.int(base + 60 + int(c.value) - 8) //nolint:mnd
+			return
+		}
+	case sgrIndexed:
+		w.indexed(base, int(c.value))
+		return
+	case sgrRGB:
+		w.int(base + 8) //nolint:mnd
+		w.buf.WriteString(";2;")
+		w.int(int(c.value >> 16))
+		w.buf.WriteByte(';')
+		w.int(int(c.value >> 8 & 0xff)) //nolint:mnd
+		w.buf.WriteByte(';')
+		w.int(int(c.value & 0xff)) //nolint:mnd
+		return
+	}
+	// The default color.
+	w.int(base + 9) //nolint:mnd
+}
+
+// indexed writes the parameters of an indexed color.
+func (w *sgrWriter) indexed(base, c int) {
+	w.int(base + 8) //nolint:mnd
+	w.buf.WriteString(";5;")
+	w.int(c)
+}
+
+// shift converts a 16-bit color component to 8 bits.
+func shift(v uint32) uint32 {
+	if v > 0xff { //nolint:mnd
+		return v >> 8 //nolint:mnd
+	}
+	return v
+}
+
+// sgrColor is a color of an SGR sequence. Unlike a [color.Color], it
+// doesn't allocate, as it's never boxed in an interface.
+type sgrColor struct {
+	kind sgrColorKind
+	// value is the basic or indexed color number, or the RGB color as
+	// 0xRRGGBB.
+	value uint32
+}
+
+// sgrColorKind is the kind of an sgrColor.
+type sgrColorKind byte
+
+// The kinds of sgrColor. The zero sgrColor is the default color.
+const (
+	sgrDefault sgrColorKind = iota
+	sgrBasic
+	sgrIndexed
+	sgrRGB
+)
+
+// basicColor returns a basic color, 0 to 15.
+func basicColor(c int) sgrColor {
+	return sgrColor{kind: sgrBasic, value: uint32(c)} //nolint:gosec
+}
+
+// colorOf returns the sgrColor of c, nil being the default color.
+func colorOf(c color.Color) sgrColor {
+	switch c := c.(type) {
+	case nil:
+		return sgrColor{}
+	case ansi.BasicColor:
+		return sgrColor{kind: sgrBasic, value: uint32(c)}
+	case ansi.IndexedColor:
+		return sgrColor{kind: sgrIndexed, value: uint32(c)}
+	}
+	r, g, b, _ := c.RGBA()
+	return sgrColor{kind: sgrRGB, value: shift(r)<<16 | shift(g)<<8 | shift(b)}
+}
+
+// readColor reads the 38, 48, or 58 color parameters at the start of params
+// like [ansi.ReadStyleColor], and returns the color converted to the color
+// profile like [Profile.Convert], and the number of parameters read. The
+// common indexed and RGB forms are converted directly, so they don't
+// allocate.
+func readColor(profile Profile, params ansi.Params) (sgrColor, int) {
+	if len(params) >= 3 && params[0].HasMore() == params[1].HasMore() { //nolint:mnd
+		colon := params[0].HasMore()
+		switch params[1].Param(0) {
+		case 5: // 38;5;n or 38:5:n
+			if !params[2].HasMore() {
+				i := uint8(params[2].Param(0)) //nolint:gosec
+				c := sgrColor{kind: sgrIndexed, value: uint32(i)}
+				return c.convert(profile), 3 //nolint:mnd
+			}
+		case 2: // 38;2;r;g;b or 38:2:r:g:b
+			if len(params) >= 5 && params[2].HasMore() == colon && //nolint:mnd
+				params[3].HasMore() == colon && !params[4].HasMore() {
+				r := uint32(uint8(params[2].Param(0))) //nolint:gosec
+				g := uint32(uint8(params[3].Param(0))) //nolint:gosec
+				b := uint32(uint8(params[4].Param(0))) //nolint:gosec
+				c := sgrColor{kind: sgrRGB, value: r<<16 | g<<8 | b}
+				return c.convert(profile), 5 //nolint:mnd
+			}
 		}
 	}
 
-	_, _ = buf.WriteString(style.String())
+	var c color.Color
+	n := ansi.ReadStyleColor(params, &c)
+	if c != nil {
+		c = profile.Convert(c)
+	}
+	return colorOf(c), n
+}
+
+// convert converts an indexed or RGB color to the ANSI256 or ANSI color
+// profile like [Profile.Convert]. Other profiles keep the color.
+func (c sgrColor) convert(profile Profile) sgrColor {
+	if profile != ANSI256 && profile != ANSI {
+		return c
+	}
+	switch c.kind {
+	case sgrIndexed:
+		if profile == ANSI {
+			return basicColor(int(ansi.Convert16(ansi.IndexedColor(c.value)))) //nolint:gosec
+		}
+	case sgrRGB:
+		i := convert256(c.value)
+		if profile == ANSI {
+			return basicColor(int(ansi.Convert16(i)))
+		}
+		return sgrColor{kind: sgrIndexed, value: uint32(i)}
+	}
+	return c
+}
+
+// rgbCache caches the 256 color conversions of RGB colors, keyed by
+// 0xRRGGBB, so converting them again doesn't allocate.
+var (
+	rgbCache   = map[uint32]ansi.IndexedColor{}
+	rgbCacheMu sync.RWMutex
+)
+
+// convert256 converts an 0xRRGGBB color like [ansi.Convert256].
+func convert256(rgb uint32) ansi.IndexedColor {
+	rgbCacheMu.RLock()
+	i, ok := rgbCache[rgb]
+	rgbCacheMu.RUnlock()
+	if ok {
+		return i
+	}
+
+	i = ansi.Convert256(color.RGBA{
+		R: uint8(rgb >> 16), //nolint:gosec,mnd
+		G: uint8(rgb >> 8),  //nolint:gosec,mnd
+		B: uint8(rgb),       //nolint:gosec
+		A: 0xff,
+	})
+	rgbCacheMu.Lock()
+	rgbCache[rgb] = i
+	rgbCacheMu.Unlock()
+	return i
+}
+
+// background converts a bright background color to a normal one if the
+// terminal doesn't render bright backgrounds.
+func (c sgrColor) background(limits Limits) sgrColor {
+	if limits.NoBrightBackground && (c.kind == sgrBasic || c.kind == sgrIndexed) &&
+		c.value >= 8 && c.value < 16 { //nolint:mnd
+		return basicColor(int(c.value) - 8) //nolint:mnd
+	}
+	return c
+}
+
+// handleConsoleSgr applies the parameters of an SGR sequence to legacy
+// console attributes. Attributes legacy consoles can't render are ignored.
+func handleConsoleSgr(profile Profile, p *ansi.Parser, attrs *ConsoleAttributes) {
+	params := p.Params()
+	if len(params) == 0 {
+		*attrs = ConsoleAttributes{}
+		return
+	}
+
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+
+		switch param := param.Param(0); param {
+		case 0:
+			*attrs = ConsoleAttributes{}
+		case 1:
+			attrs.Bold = true
+		case 7:
+			attrs.Reverse = true
+		case 22:
+			attrs.Bold = false
+		case 27:
+			attrs.Reverse = false
+		case 30, 31, 32, 33, 34, 35, 36, 37: // 8-bit foreground color
+			if profile < ANSI {
+				continue
+			}
+			attrs.Foreground = ansi.BasicColor(param - 30) //nolint:gosec
+		case 38, 48: // 16 or 24-bit foreground or background color
+			c, n := readColor(ANSI, params[i:])
+			if n > 0 {
+				i += n - 1
+			}
+			if profile < ANSI || c.kind == sgrDefault {
+				continue
+			}
+			bc := ansi.BasicColor(c.value) //nolint:gosec
+			if param == 38 {
+				attrs.Foreground = bc
+			} else {
+				attrs.Background = bc
+			}
+		case 39: // default foreground color
+			attrs.Foreground = nil
+		case 40, 41, 42, 43, 44, 45, 46, 47: // 8-bit background color
+			if profile < ANSI {
+				continue
+			}
+			attrs.Background = ansi.BasicColor(param - 40) //nolint:gosec
+		case 49: // default background color
+			attrs.Background = nil
+		case 58: // 16 or 24-bit underline color
+			if _, n := readColor(ANSI, params[i:]); n > 0 {
+				i += n - 1
+			}
+		case 90, 91, 92, 93, 94, 95, 96, 97: // 8-bit bright foreground color
+			if profile < ANSI {
+				continue
+			}
+			attrs.Foreground = ansi.BasicColor(param - 90 + 8) //nolint:gosec
+		case 100, 101, 102, 103, 104, 105, 106, 107: // 8-bit bright background color
+			if profile < ANSI {
+				continue
+			}
+			attrs.Background = ansi.BasicColor(param - 100 + 8) //nolint:gosec
+		}
+	}
 }
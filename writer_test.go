@@ -3,9 +3,14 @@ package colorprofile
 import (
 	"bufio"
 	"bytes"
+	"fmt"
+	"image/color"
 	"io"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/charmbracelet/x/ansi"
 )
@@ -211,3 +216,421 @@ func BenchmarkWriter(b *testing.B) {
 		})
 	}
 }
+
+// recordingConsole records the text and the attributes a legacy console
+// writer sends, in order.
+type recordingConsole struct {
+	ops []any
+}
+
+func (c *recordingConsole) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		c.ops = append(c.ops, string(p))
+	}
+	return len(p), nil
+}
+
+func (c *recordingConsole) SetAttributes(attrs ConsoleAttributes) error {
+	c.ops = append(c.ops, attrs)
+	return nil
+}
+
+func TestWriterConsole(t *testing.T) {
+	cases := []struct {
+		name     string
+		profile  Profile
+		input    []string
+		expected []any
+	}{
+		{
+			name:     "no styles",
+			profile:  ANSI,
+			input:    []string{"hello world"},
+			expected: []any{"hello world"},
+		},
+		{
+			name:    "basic colors",
+			profile: ANSI,
+			input:   []string{"hello \x1b[31;44mworld\x1b[m!"},
+			expected: []any{
+				"hello ",
+				ConsoleAttributes{Foreground: ansi.Red, Background: ansi.Blue},
+				"world",
+				ConsoleAttributes{},
+				"!",
+			},
+		},
+		{
+			name:    "bright colors and intensity",
+			profile: ANSI,
+			input:   []string{"\x1b[1;92;103mhi\x1b[22;39;49m"},
+			expected: []any{
+				ConsoleAttributes{Foreground: ansi.BrightGreen, Background: ansi.BrightYellow, Bold: true},
+				"hi",
+				ConsoleAttributes{},
+			},
+		},
+		{
+			name:    "true colors",
+			profile: ANSI,
+			input:   []string{"\x1b[38;2;255;0;0;48;5;21mhi"},
+			expected: []any{
+				ConsoleAttributes{Foreground: ansi.BrightRed, Background: ansi.BrightBlue},
+				"hi",
+			},
+		},
+		{
+			name:    "unsupported attributes",
+			profile: ANSI,
+			input:   []string{"\x1b[3;4mhi\x1b[58;5;1m there"},
+			expected: []any{
+				"hi there",
+			},
+		},
+		{
+			name:    "other sequences are stripped",
+			profile: ANSI,
+			input:   []string{"\x1b[2J\x1b]0;title\x07hi\r\n"},
+			expected: []any{
+				"hi\r\n",
+			},
+		},
+		{
+			name:    "reverse",
+			profile: ANSI,
+			input:   []string{"\x1b[7mhi\x1b[27m"},
+			expected: []any{
+				ConsoleAttributes{Reverse: true},
+				"hi",
+				ConsoleAttributes{},
+			},
+		},
+		{
+			name:    "attributes persist across writes",
+			profile: ANSI,
+			input:   []string{"\x1b[32mhello", " \x1b[32mworld"},
+			expected: []any{
+				ConsoleAttributes{Foreground: ansi.Green},
+				"hello",
+				" world",
+			},
+		},
+		{
+			name:    "ascii",
+			profile: ASCII,
+			input:   []string{"\x1b[1;31mhi"},
+			expected: []any{
+				ConsoleAttributes{Bold: true},
+				"hi",
+			},
+		},
+		{
+			name:    "notty",
+			profile: NoTTY,
+			input:   []string{"\x1b[1;31mhi"},
+			expected: []any{
+				"hi",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c recordingConsole
+			w := &Writer{Forward: &Output{Writer: &c, Console: &c}, Profile: tc.profile}
+			for _, s := range tc.input {
+				n, err := w.WriteString(s)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if n != len(s) {
+					t.Errorf("expected %d bytes written, got %d", len(s), n)
+				}
+			}
+			if !reflect.DeepEqual(c.ops, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, c.ops)
+			}
+		})
+	}
+}
+
+func TestWriterLimits(t *testing.T) {
+	cases := []struct {
+		name     string
+		profile  Profile
+		limits   Limits
+		input    string
+		expected string
+	}{
+		{
+			name:     "no limits",
+			profile:  ANSI,
+			input:    "\x1b[3;101mhello\x1b[m",
+			expected: "\x1b[3;101mhello\x1b[m",
+		},
+		{
+			name:     "bright backgrounds",
+			profile:  ANSI,
+			limits:   linuxLimits,
+			input:    "\x1b[91;101mhello\x1b[m",
+			expected: "\x1b[91;41mhello\x1b[m",
+		},
+		{
+			name:     "converted bright backgrounds",
+			profile:  ANSI,
+			limits:   linuxLimits,
+			input:    "\x1b[48;2;255;0;0mhello\x1b[m",
+			expected: "\x1b[41mhello\x1b[m",
+		},
+		{
+			name:     "indexed bright backgrounds",
+			profile:  ANSI256,
+			limits:   Limits{NoBrightBackground: true},
+			input:    "\x1b[48;5;9mhi\x1b[48;5;196mthere\x1b[m",
+			expected: "\x1b[41mhi\x1b[48;5;196mthere\x1b[m",
+		},
+		{
+			name:     "unsupported attributes",
+			profile:  ANSI,
+			limits:   linuxLimits,
+			input:    "\x1b[1;3;9;53mhello\x1b[23;29;55m world\x1b[m",
+			expected: "\x1b[1mhello\x1b[23;29;55m world\x1b[m",
+		},
+		{
+			name:     "underline color",
+			profile:  ANSI256,
+			limits:   linuxLimits,
+			input:    "\x1b[4;58;5;196mhello\x1b[m",
+			expected: "\x1b[4mhello\x1b[m",
+		},
+		{
+			name:     "underline style",
+			profile:  ANSI,
+			limits:   Limits{Unsupported: AttrUnderline},
+			input:    "\x1b[4:3;31mhello\x1b[m",
+			expected: "\x1b[31mhello\x1b[m",
+		},
+		{
+			name:     "true color",
+			profile:  TrueColor,
+			limits:   kmsconLimits,
+			input:    "\x1b[2;38;2;255;0;0mhello\x1b[m",
+			expected: "\x1b[38;2;255;0;0mhello\x1b[m",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &Writer{Forward: &Output{Writer: &buf, Limits: tc.limits}, Profile: tc.profile}
+			if _, err := w.WriteString(tc.input); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestWriterStrip(t *testing.T) {
+	for _, s := range []string{
+		"plain text\twith\x00controls\x7f\n",
+		"\x1b[1;31mhello\x1b[m \x1b]8;;https://charm.sh\x07link\x1b]8;;\x07",
+		"caf\u00e9 \U0001f600 \x1b[38;2;107;80;255m\u6f22\u5b57\x1b[m",
+		"\x9b31mC1\x9bm and invalid \xff\xfe UTF-8",
+		"unterminated \x1b[38;5",
+	} {
+		var buf bytes.Buffer
+		if _, err := (&Writer{&buf, NoTTY}).WriteString(s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := ansi.Strip(s); buf.String() != expected {
+			t.Errorf("%q: expected %q, got %q", s, expected, buf.String())
+		}
+	}
+}
+
+func TestSgrWriterColor(t *testing.T) {
+	colors := []ansi.Color{
+		nil,
+		ansi.Red,
+		ansi.BrightCyan,
+		ansi.IndexedColor(63),
+		ansi.TrueColor(0x6b50ff),
+		color.RGBA{R: 0x6b, G: 0x50, B: 0xff, A: 0xff},
+	}
+	for _, c := range colors {
+		for _, base := range []int{sgrForeground, sgrBackground, sgrUnderline} {
+			var buf bytes.Buffer
+			w := sgrWriter{buf: &buf}
+			w.color(base, colorOf(c))
+
+			var style ansi.Style
+			switch base {
+			case sgrForeground:
+				style = style.ForegroundColor(c)
+			case sgrBackground:
+				style = style.BackgroundColor(c)
+			case sgrUnderline:
+				style = style.UnderlineColor(c)
+			}
+			if expected := strings.Join(style, ";"); buf.String() != expected {
+				t.Errorf("%d, %#v: expected %q, got %q", base, c, expected, buf.String())
+			}
+		}
+	}
+}
+
+func TestWriterReadFrom(t *testing.T) {
+	readers := map[string]func(string) io.Reader{
+		"half":     func(s string) io.Reader { return iotest.HalfReader(strings.NewReader(s)) },
+		"one byte": func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+	}
+	inputs := []string{"héllo wörld\x1b]8;;https://charm.sh\x1b\\link\x1b]8;;\x1b\\ ✨"}
+	for _, c := range writer_cases {
+		inputs = append(inputs, c.input)
+	}
+
+	for _, input := range inputs {
+		for profile, writer := range writers {
+			var expected bytes.Buffer
+			if _, err := writer(&expected).Write([]byte(input)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, reader := range readers {
+				t.Run(profile.String()+"/"+name, func(t *testing.T) {
+					var buf bytes.Buffer
+					n, err := writer(&buf).ReadFrom(reader(input))
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					if n != int64(len(input)) {
+						t.Errorf("expected %d bytes read, got %d", len(input), n)
+					}
+					if buf.String() != expected.String() {
+						t.Errorf("input %q: expected %q, got %q", input, expected.String(), buf.String())
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestWriterReadFromError(t *testing.T) {
+	var buf bytes.Buffer
+	w := &Writer{Forward: &buf, Profile: ANSI}
+	r := io.MultiReader(strings.NewReader("hello \x1b[38;2;255;0;0mworld"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	if _, err := w.ReadFrom(r); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	if expected := "hello \x1b[91mworld"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestWriterWriteString(t *testing.T) {
+	for _, c := range writer_cases {
+		for profile, writer := range writers {
+			t.Run(c.name+"-"+profile.String(), func(t *testing.T) {
+				var expected, buf bytes.Buffer
+				if _, err := writer(&expected).Write([]byte(c.input)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if _, err := writer(&buf).WriteString(c.input); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if buf.String() != expected.String() {
+					t.Errorf("expected %q, got %q", expected.String(), buf.String())
+				}
+			})
+		}
+	}
+}
+
+// sgrParser returns a parser holding the parameters of an SGR sequence.
+func sgrParser(seq string) *ansi.Parser {
+	p := ansi.NewParser()
+	ansi.DecodeSequence(seq, ansi.NormalState, p)
+	return p
+}
+
+func TestReadColor(t *testing.T) {
+	params := []string{
+		"38;5;63", "38:5:63", "48;5;300", "38;5", "38;2;1;2", "38:2::1:2:3",
+		"38:3::1:2:3", "38:4::1:2:3:4", "38:6::1:2:3:4", "38;1", "38;0", "38;7;1",
+	}
+	for r := 0; r < 256; r += 15 {
+		for g := 0; g < 256; g += 15 {
+			for b := 0; b < 256; b += 15 {
+				params = append(params,
+					fmt.Sprintf("38;2;%d;%d;%d", r, g, b),
+					fmt.Sprintf("48:2:%d:%d:%d", r, g, b))
+			}
+		}
+	}
+
+	// readColor reads and converts colors like ansi.ReadStyleColor and
+	// Profile.Convert.
+	for _, param := range params {
+		ps := sgrParser("\x1b[" + param + "m").Params()
+		var c color.Color
+		expectedN := ansi.ReadStyleColor(ps, &c)
+		for _, profile := range []Profile{TrueColor, ANSI256, ANSI} {
+			expected := colorOf(c)
+			if c != nil {
+				expected = colorOf(profile.Convert(c))
+			}
+			got, n := readColor(profile, ps)
+			if n != expectedN {
+				t.Errorf("%s: expected %d parameters read, got %d", param, expectedN, n)
+			}
+			if got != expected {
+				t.Errorf("%s, %v: expected %v, got %v", param, profile, expected, got)
+			}
+		}
+	}
+}
+
+func TestHandleSgrAllocs(t *testing.T) {
+	seqs := []string{
+		"\x1b[1;38;2;107;80;255;48:2:1:2:3m",
+		"\x1b[38;5;63;48:5:196;58;5;9m",
+		"\x1b[32;101;4:3m",
+	}
+	limits := Limits{Unsupported: AttrItalic | AttrUnderline, NoBrightBackground: true}
+	for _, profile := range []Profile{TrueColor, ANSI256, ANSI, ASCII} {
+		for _, seq := range seqs {
+			p := sgrParser(seq)
+			var buf bytes.Buffer
+			allocs := testing.AllocsPerRun(100, func() {
+				buf.Reset()
+				handleSgr(profile, limits, p, &buf)
+			})
+			if allocs > 0 {
+				t.Errorf("%v, %q: expected no allocations, got %v", profile, seq, allocs)
+			}
+		}
+	}
+}
+
+// BenchmarkWriterReadFrom copies a 1MB ANSI log into a Writer, and reports
+// the allocations per MB processed as allocs/op.
+func BenchmarkWriterReadFrom(b *testing.B) {
+	line := "2024-10-19T12:00:00Z \x1b[32mINFO\x1b[m \x1b[38;2;107;80;255mserver\x1b[m: request handled in \x1b[1m12ms\x1b[m\n" +
+		"2024-10-19T12:00:01Z plain text without any escape sequences, which is most of a log\n"
+	log := []byte(strings.Repeat(line, (1<<20)/len(line)))
+	for _, profile := range []Profile{TrueColor, ANSI256, ANSI, NoTTY} {
+		w := &Writer{Profile: profile, Forward: io.Discard}
+		b.Run(profile.String(), func(b *testing.B) {
+			b.SetBytes(int64(len(log)))
+			b.ReportAllocs()
+			for b.Loop() {
+				// Hide WriterTo, so io.Copy uses ReadFrom like it does for files.
+				if _, err := io.Copy(w, struct{ io.Reader }{bytes.NewReader(log)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}